@@ -0,0 +1,98 @@
+package acl
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Checker rejects the calls from the not allowed addresses with PERMISSION_DENIED.
+type Checker struct {
+	cfg      *Config
+	log      *slog.Logger
+	services map[string]*ServiceRules
+}
+
+func NewChecker(cfg *Config, log *slog.Logger) *Checker {
+	services := make(map[string]*ServiceRules, len(cfg.Services))
+	for _, s := range cfg.Services {
+		services[s.Service] = s
+	}
+
+	return &Checker{cfg: cfg, log: log, services: services}
+}
+
+func (c *Checker) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		err := c.check(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (c *Checker) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := c.check(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// check checks the client address of the call against the server and the service lists. The address is taken
+// from the PROXY header when it is enabled, the unix socket clients are not checked.
+func (c *Checker) check(ctx context.Context, fullMethod string) error {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.PermissionDenied, "unknown client address")
+	}
+
+	addr, ok := clientAddr(pr.Addr)
+	if !ok {
+		return nil
+	}
+
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	allowed := c.cfg.rules.allowed(addr)
+	if sr := c.services[service]; sr != nil {
+		allowed = (allowed || sr.OverrideServer) && sr.rules.allowed(addr)
+	}
+
+	if allowed {
+		return nil
+	}
+
+	c.log.Debug("call was denied by the client address", "method", fullMethod, "address", addr.String())
+	return status.Errorf(codes.PermissionDenied, "address %s is not allowed to call %s", addr, service)
+}
+
+// clientAddr returns the IP of the client address, false for the non-IP (unix socket) clients.
+func clientAddr(addr net.Addr) (netip.Addr, bool) {
+	if addr == nil {
+		return netip.Addr{}, false
+	}
+
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.AddrPort().Addr().Unmap(), true
+	}
+
+	// the HTTP handlers report the request address as is
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return ap.Addr().Unmap(), true
+}
@@ -0,0 +1,164 @@
+package acl
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// stringAddr is the address reported by the HTTP handlers.
+type stringAddr string
+
+func (a stringAddr) Network() string { return "tcp" }
+func (a stringAddr) String() string  { return string(a) }
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func peerContext(addr net.Addr) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+}
+
+func TestChecker(t *testing.T) {
+	cfg := &Config{
+		Deny: []string{"10.0.0.66"},
+		Services: []*ServiceRules{
+			{Service: "/app.Admin/", Allow: []string{"10.0.0.0/8", "::1"}, Deny: []string{"10.1.0.0/16"}},
+		},
+	}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, "app.Admin", cfg.Services[0].Service)
+
+	checker := NewChecker(cfg, slog.New(slog.DiscardHandler))
+	unary := checker.UnaryServerInterceptor()
+
+	call := func(addr net.Addr, method string) codes.Code {
+		_, err := unary(peerContext(addr), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, any) (any, error) {
+			return "ok", nil
+		})
+		return status.Code(err)
+	}
+
+	tests := []struct {
+		addr     net.Addr
+		method   string
+		expected codes.Code
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5000}, "/app.Service/Method", codes.OK},
+		{&net.TCPAddr{IP: net.ParseIP("10.0.0.66"), Port: 5000}, "/app.Service/Method", codes.PermissionDenied},
+		{&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5000}, "/app.Admin/Method", codes.PermissionDenied},
+		{&net.TCPAddr{IP: net.ParseIP("10.2.0.1"), Port: 5000}, "/app.Admin/Method", codes.OK},
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:10.2.0.1"), Port: 5000}, "/app.Admin/Method", codes.OK},
+		{&net.TCPAddr{IP: net.ParseIP("10.1.0.1"), Port: 5000}, "/app.Admin/Method", codes.PermissionDenied},
+		{&net.TCPAddr{IP: net.ParseIP("10.0.0.66"), Port: 5000}, "/app.Admin/Method", codes.PermissionDenied},
+		{stringAddr("[::1]:5000"), "/app.Admin/Method", codes.OK},
+		{stringAddr("192.0.2.1:5000"), "/app.Admin/Method", codes.PermissionDenied},
+		{&net.UnixAddr{Name: "/tmp/grpc.sock", Net: "unix"}, "/app.Admin/Method", codes.OK},
+		// the health checks are subject to the server lists as any other service
+		{&net.TCPAddr{IP: net.ParseIP("10.0.0.66"), Port: 5000}, "/grpc.health.v1.Health/Check", codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, call(tt.addr, tt.method), "%s %s", tt.addr, tt.method)
+	}
+
+	_, err := unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/app.Service/Method"}, nil)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream := checker.StreamServerInterceptor()
+	err = stream(nil, &fakeStream{ctx: peerContext(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")})}, &grpc.StreamServerInfo{FullMethod: "/app.Admin/Watch"}, func(any, grpc.ServerStream) error {
+		return nil
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	err = stream(nil, &fakeStream{ctx: peerContext(&net.TCPAddr{IP: net.ParseIP("10.0.0.1")})}, &grpc.StreamServerInfo{FullMethod: "/app.Admin/Watch"}, func(any, grpc.ServerStream) error {
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestCheckerOverrideServer(t *testing.T) {
+	// deny by default, the health service is restricted by its own lists only
+	cfg := &Config{
+		Allow: []string{"10.0.0.0/8"},
+		Services: []*ServiceRules{
+			{Service: "grpc.health.v1.Health", Deny: []string{"192.0.2.66"}, OverrideServer: true},
+			{Service: "app.Admin", Deny: []string{"10.0.0.66"}},
+		},
+	}
+	require.NoError(t, cfg.InitDefaults())
+
+	unary := NewChecker(cfg, slog.New(slog.DiscardHandler)).UnaryServerInterceptor()
+	call := func(ip, method string) codes.Code {
+		_, err := unary(peerContext(&net.TCPAddr{IP: net.ParseIP(ip), Port: 5000}), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, any) (any, error) {
+			return "ok", nil
+		})
+		return status.Code(err)
+	}
+
+	assert.Equal(t, codes.PermissionDenied, call("192.0.2.1", "/app.Service/Method"))
+	assert.Equal(t, codes.OK, call("192.0.2.1", "/grpc.health.v1.Health/Check"))
+	assert.Equal(t, codes.PermissionDenied, call("192.0.2.66", "/grpc.health.v1.Health/Check"))
+	// the admin services are checked against the server lists
+	assert.Equal(t, codes.PermissionDenied, call("192.0.2.1", "/grpc.channelz.v1.Channelz/GetServers"))
+
+	// both lists apply without the override
+	assert.Equal(t, codes.OK, call("10.0.0.1", "/app.Admin/Method"))
+	assert.Equal(t, codes.PermissionDenied, call("10.0.0.66", "/app.Admin/Method"))
+	assert.Equal(t, codes.PermissionDenied, call("192.0.2.1", "/app.Admin/Method"))
+}
+
+func TestCheckerMappedPrefixes(t *testing.T) {
+	cfg := &Config{
+		Allow: []string{"::ffff:10.0.0.0/104"},
+		Deny:  []string{"::ffff:10.0.0.66"},
+	}
+	require.NoError(t, cfg.InitDefaults())
+
+	unary := NewChecker(cfg, slog.New(slog.DiscardHandler)).UnaryServerInterceptor()
+	call := func(addr net.Addr) codes.Code {
+		_, err := unary(peerContext(addr), nil, &grpc.UnaryServerInfo{FullMethod: "/app.Service/Method"}, func(context.Context, any) (any, error) {
+			return "ok", nil
+		})
+		return status.Code(err)
+	}
+
+	assert.Equal(t, codes.OK, call(&net.TCPAddr{IP: net.ParseIP("10.1.2.3").To4(), Port: 5000}))
+	assert.Equal(t, codes.OK, call(&net.TCPAddr{IP: net.ParseIP("::ffff:10.1.2.3"), Port: 5000}))
+	assert.Equal(t, codes.OK, call(stringAddr("[::ffff:10.1.2.3]:5000")))
+	assert.Equal(t, codes.PermissionDenied, call(&net.TCPAddr{IP: net.ParseIP("10.0.0.66").To4(), Port: 5000}))
+	assert.Equal(t, codes.PermissionDenied, call(&net.TCPAddr{IP: net.ParseIP("::ffff:10.0.0.66"), Port: 5000}))
+	assert.Equal(t, codes.PermissionDenied, call(&net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 5000}))
+
+	// the IPv4 CIDRs match the mapped addresses too
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), cfg.rules.allow[0])
+}
+
+func TestConfigInitDefaults(t *testing.T) {
+	cfg := &Config{Allow: []string{"10.0.0.0/33"}}
+	assert.ErrorContains(t, cfg.InitDefaults(), "malformed CIDR")
+
+	cfg = &Config{Services: []*ServiceRules{{Deny: []string{"10.0.0.1"}}}}
+	assert.ErrorContains(t, cfg.InitDefaults(), "service name should be set")
+
+	cfg = &Config{Services: []*ServiceRules{{Service: "app.Admin"}, {Service: "app.Admin"}}}
+	assert.ErrorContains(t, cfg.InitDefaults(), "duplicated service")
+
+	cfg = &Config{Services: []*ServiceRules{{Service: "app.Admin", Allow: []string{"localhost"}}}}
+	assert.ErrorContains(t, cfg.InitDefaults(), "service app.Admin: malformed address")
+}
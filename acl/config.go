@@ -0,0 +1,135 @@
+// Package acl checks the client addresses of the calls against the CIDR allow and deny lists of the server
+// and the services.
+package acl
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Config is the server-level lists with the per-service ones. The lists are CIDRs (or the single addresses), the
+// deny list takes precedence, all the addresses not denied are allowed when the allow list is empty. A call should
+// pass both the server and its service lists, unless the service lists override the server ones. The IPv4-mapped IPv6 addresses and CIDRs are matched as IPv4.
+type Config struct {
+	Allow []string `mapstructure:"allow"`
+	Deny  []string `mapstructure:"deny"`
+	// Services are the lists of the single services
+	Services []*ServiceRules `mapstructure:"services"`
+
+	rules rules
+}
+
+// ServiceRules are the lists of the service.
+type ServiceRules struct {
+	// Service is the fully-qualified service name, e.g. `package.Service`.
+	Service string   `mapstructure:"service"`
+	Allow   []string `mapstructure:"allow"`
+	Deny    []string `mapstructure:"deny"`
+	// OverrideServer checks the calls of the service only against its lists, e.g. to let the load balancers
+	// call grpc.health.v1.Health past a deny-by-default server list.
+	OverrideServer bool `mapstructure:"override_server"`
+
+	rules rules
+}
+
+type rules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_acl_init")
+
+	var err error
+	c.rules, err = newRules(c.Allow, c.Deny)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	seen := make(map[string]struct{}, len(c.Services))
+	for i, s := range c.Services {
+		if s == nil || s.Service == "" {
+			return errors.E(op, errors.Errorf("services[%d]: service name should be set", i))
+		}
+
+		s.Service = strings.Trim(s.Service, "/")
+		if _, ok := seen[s.Service]; ok {
+			return errors.E(op, errors.Errorf("duplicated service %s", s.Service))
+		}
+		seen[s.Service] = struct{}{}
+
+		s.rules, err = newRules(s.Allow, s.Deny)
+		if err != nil {
+			return errors.E(op, errors.Errorf("service %s: %v", s.Service, err))
+		}
+	}
+
+	return nil
+}
+
+func newRules(allow, deny []string) (rules, error) {
+	var r rules
+	var err error
+
+	r.allow, err = parsePrefixes(allow)
+	if err != nil {
+		return r, err
+	}
+
+	r.deny, err = parsePrefixes(deny)
+	return r, err
+}
+
+// allowed reports whether the address passes the lists.
+func (r *rules) allowed(addr netip.Addr) bool {
+	for _, p := range r.deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+
+	if len(r.allow) == 0 {
+		return true
+	}
+
+	for _, p := range r.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, errors.Errorf("malformed address %s: %v", cidr, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, errors.Errorf("malformed CIDR %s: %v", cidr, err)
+		}
+		prefixes = append(prefixes, unmap(prefix).Masked())
+	}
+
+	return prefixes, nil
+}
+
+// unmap converts the IPv4-mapped IPv6 prefix (::ffff:a.b.c.d/n) to the IPv4 one, the client addresses are unmapped too.
+func unmap(prefix netip.Prefix) netip.Prefix {
+	if !prefix.Addr().Is4In6() || prefix.Bits() < 96 {
+		return prefix
+	}
+
+	return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+}
@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	stderr "errors"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/tcplisten"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/admin"
+)
+
+// serveAdmin registers the admin services (channelz) on the plugin server, or serves them on the separate
+// listener when the address is configured, the serving errors are sent to errCh.
+func (p *Plugin) serveAdmin(errCh chan error) error {
+	const op = errors.Op("grpc_plugin_serve_admin")
+
+	if p.config.Admin.Address == "" {
+		cleanup, err := admin.Register(p.server)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		p.adminCleanup = cleanup
+		return nil
+	}
+
+	p.adminSrv = grpc.NewServer()
+	cleanup, err := admin.Register(p.adminSrv)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	p.adminCleanup = cleanup
+
+	l, err := tcplisten.CreateListener(p.config.Admin.Address)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	go func() {
+		p.log.Info("admin server was started", "address", p.config.Admin.Address)
+
+		errS := p.adminSrv.Serve(l)
+		if errS != nil && !stderr.Is(errS, grpc.ErrServerStopped) {
+			p.log.Error("admin server was stopped", "error", errS)
+			errCh <- errors.E(op, errS)
+		}
+	}()
+
+	return nil
+}
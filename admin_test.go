@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestServeAdmin(t *testing.T) {
+	// registered on the plugin server
+	p := &Plugin{
+		config: &Config{Admin: &Admin{}},
+		log:    slog.New(slog.DiscardHandler),
+		server: grpc.NewServer(),
+	}
+	require.NoError(t, p.serveAdmin(make(chan error, 1)))
+	assert.Contains(t, p.server.GetServiceInfo(), "grpc.channelz.v1.Channelz")
+	assert.Nil(t, p.adminSrv)
+	p.adminCleanup()
+
+	// served on the separate listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	p = &Plugin{
+		config: &Config{Admin: &Admin{Address: address}},
+		log:    slog.New(slog.DiscardHandler),
+		server: grpc.NewServer(),
+	}
+	require.NoError(t, p.serveAdmin(make(chan error, 1)))
+	t.Cleanup(p.adminSrv.Stop)
+	assert.NotContains(t, p.server.GetServiceInfo(), "grpc.channelz.v1.Channelz")
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	resp, err := channelzpb.NewChannelzClient(conn).GetServers(context.Background(), &channelzpb.GetServersRequest{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.GetServer())
+}
@@ -11,6 +11,7 @@ import (
 	staticPool "github.com/roadrunner-server/pool/v2/pool/static_pool"
 	"github.com/roadrunner-server/pool/v2/worker"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 type Interceptor interface {
@@ -25,6 +26,13 @@ type Registry interface {
 	Registry() *protoresolve.Registry
 }
 
+// Invoker calls a unary method of the gRPC server in-process, through the same interceptors as the network calls.
+// It is used by the protocol translators (HTTP/JSON transcoding, Connect). The method is in the /package.Service/Method
+// form, the request and the response are the serialized protobuf messages.
+type Invoker interface {
+	Invoke(ctx context.Context, method string, in []byte) (out []byte, header, trailer metadata.MD, err error)
+}
+
 type Configurer interface {
 	// UnmarshalKey takes a single key and unmarshal it into a Struct.
 	UnmarshalKey(name string, out any) error
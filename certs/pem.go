@@ -0,0 +1,20 @@
+package certs
+
+import (
+	"os"
+	"strings"
+)
+
+// IsInline reports whether the value is the PEM material itself rather than a file path.
+func IsInline(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN")
+}
+
+// ReadPEM returns the inline PEM material or reads it from the file.
+func ReadPEM(value string) ([]byte, error) {
+	if IsInline(value) {
+		return []byte(value), nil
+	}
+
+	return os.ReadFile(value)
+}
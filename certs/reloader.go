@@ -0,0 +1,339 @@
+// Package certs serves the server TLS certificates through tls.Config callbacks, so the certificates, the keys and
+// the root CA could be reloaded from the files without restarting the server. The certificate is selected by the
+// server name (SNI) of the client.
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/errors"
+)
+
+// the files are usually replaced by a few operations (e.g. the kubernetes secrets update), the reload waits
+// for the last of them
+const debounce = time.Millisecond * 100
+
+// Files are the server certificates and the optional root CA of the client certificates. Each value is either
+// a file path or the inline PEM material.
+type Files struct {
+	// Certificates are selected by the server names in their SANs, the first one is the default
+	Certificates []KeyPair
+	RootCA       string
+	// ClientAuth is used only with the root CA
+	ClientAuth tls.ClientAuthType
+}
+
+// KeyPair is a certificate (chain) with its private key.
+type KeyPair struct {
+	Cert string
+	Key  string
+}
+
+// state is the loaded configuration.
+type state struct {
+	// config is returned for the clients, without the certificates
+	config *tls.Config
+	certs  []*tls.Certificate
+	// byName are the certificates by their DNS names, including the wildcard ones
+	byName map[string]*tls.Certificate
+}
+
+// Reloader keeps the TLS configuration loaded from the files.
+type Reloader struct {
+	files Files
+	log   *slog.Logger
+	// expiry is the certificate expiry time by the certificate file
+	expiry *prometheus.GaugeVec
+
+	state atomic.Pointer[state]
+	// base is returned by TLSConfig, the connection settings (e.g. NextProtos) are copied from it
+	base *tls.Config
+
+	watcher *fsnotify.Watcher
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewReloader loads the files, the expiry gauge might be nil.
+func NewReloader(files Files, log *slog.Logger, expiry *prometheus.GaugeVec) (*Reloader, error) {
+	r := &Reloader{
+		files:  files,
+		log:    log,
+		expiry: expiry,
+		stopCh: make(chan struct{}),
+	}
+
+	r.base = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetCertificate:     r.GetCertificate,
+		GetConfigForClient: r.GetConfigForClient,
+	}
+
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns the server configuration, the certificates are taken from the last loaded files.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return r.base
+}
+
+// GetCertificate selects the certificate by the server name: the exact name first, then the wildcard one,
+// the default certificate otherwise.
+func (r *Reloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	st := r.state.Load()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		if cert, ok := st.byName[name]; ok {
+			return cert, nil
+		}
+
+		if i := strings.IndexByte(name, '.'); i > 0 {
+			if cert, ok := st.byName["*"+name[i:]]; ok {
+				return cert, nil
+			}
+		}
+	}
+
+	return st.certs[0], nil
+}
+
+func (r *Reloader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cfg := r.state.Load().config.Clone()
+	cfg.NextProtos = r.base.NextProtos
+	return cfg, nil
+}
+
+// Reload loads the files, the previous configuration is kept on error.
+func (r *Reloader) Reload() error {
+	const op = errors.Op("grpc_tls_reload")
+
+	if len(r.files.Certificates) == 0 {
+		return errors.E(op, errors.Str("no certificates"))
+	}
+
+	st := &state{
+		config: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: r.GetCertificate,
+		},
+		certs:  make([]*tls.Certificate, 0, len(r.files.Certificates)),
+		byName: make(map[string]*tls.Certificate),
+	}
+
+	for _, kp := range r.files.Certificates {
+		cert, err := LoadKeyPair(kp)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		st.certs = append(st.certs, cert)
+		for _, name := range cert.Leaf.DNSNames {
+			name = strings.ToLower(name)
+			// the earlier certificates take precedence
+			if _, ok := st.byName[name]; !ok {
+				st.byName[name] = cert
+			}
+		}
+	}
+
+	if r.files.RootCA != "" {
+		// if client CA is not empty, we combine it with Cert and Key
+		certPool, err := LoadCertPool(r.files.RootCA)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		st.config.ClientAuth = r.files.ClientAuth
+		st.config.ClientCAs = certPool
+	}
+
+	prev := r.state.Swap(st)
+
+	for i, cert := range st.certs {
+		source := certSource(r.files.Certificates[i].Cert, cert)
+		if r.expiry != nil {
+			r.expiry.WithLabelValues(source).Set(float64(cert.Leaf.NotAfter.Unix()))
+		}
+
+		if prev != nil && (i >= len(prev.certs) || !bytes.Equal(prev.certs[i].Certificate[0], cert.Certificate[0])) {
+			r.log.Info("tls certificate was reloaded", "cert", source, "not_after", cert.Leaf.NotAfter)
+		}
+	}
+
+	return nil
+}
+
+// LoadKeyPair parses the certificate and its key.
+func LoadKeyPair(kp KeyPair) (*tls.Certificate, error) {
+	certPEM, err := ReadPEM(kp.Cert)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := ReadPEM(kp.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+// LoadCertPool returns the system pool with the root CA added.
+func LoadCertPool(rootCA string) (*x509.CertPool, error) {
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, err
+	}
+	if certPool == nil {
+		certPool = x509.NewCertPool()
+	}
+
+	rca, err := ReadPEM(rootCA)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok := certPool.AppendCertsFromPEM(rca); !ok {
+		return nil, errors.Str("could not append Certs from PEM")
+	}
+
+	return certPool, nil
+}
+
+// certSource identifies the certificate in the logs and metrics: the file path or the subject of the inline one.
+func certSource(value string, cert *tls.Certificate) string {
+	if !IsInline(value) {
+		return value
+	}
+
+	if len(cert.Leaf.DNSNames) > 0 {
+		return "inline:" + cert.Leaf.DNSNames[0]
+	}
+
+	return "inline:" + cert.Leaf.Subject.CommonName
+}
+
+// Watch reloads the files when they change, and every interval when it is positive. The reload errors are logged,
+// the previous configuration is kept.
+func (r *Reloader) Watch(interval time.Duration) error {
+	const op = errors.Op("grpc_tls_watch")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	files := []string{r.files.RootCA}
+	for _, kp := range r.files.Certificates {
+		files = append(files, kp.Cert, kp.Key)
+	}
+
+	// the directories are watched, the files might be replaced (renamed or relinked)
+	dirs := make(map[string]struct{})
+	for _, f := range files {
+		if f == "" || IsInline(f) {
+			continue
+		}
+
+		dir := filepath.Dir(f)
+		if _, ok := dirs[dir]; ok {
+			continue
+		}
+		dirs[dir] = struct{}{}
+
+		err = watcher.Add(dir)
+		if err != nil {
+			_ = watcher.Close()
+			return errors.E(op, err)
+		}
+	}
+	r.watcher = watcher
+
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		tick = ticker.C
+	}
+
+	r.wg.Go(func() {
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+
+		timer := time.NewTimer(debounce)
+		timer.Stop()
+
+		for {
+			select {
+			case <-r.stopCh:
+				timer.Stop()
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+					continue
+				}
+				timer.Reset(debounce)
+			case errW, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.log.Warn("tls files watcher error", "error", errW)
+			case <-timer.C:
+				r.reload()
+			case <-tick:
+				r.reload()
+			}
+		}
+	})
+
+	return nil
+}
+
+func (r *Reloader) reload() {
+	err := r.Reload()
+	if err != nil {
+		r.log.Error("tls certificates were not reloaded, the previous ones are used", "error", err)
+	}
+}
+
+// Stop stops watching the files.
+func (r *Reloader) Stop() {
+	select {
+	case <-r.stopCh:
+		return
+	default:
+		close(r.stopCh)
+	}
+
+	if r.watcher != nil {
+		_ = r.watcher.Close()
+	}
+
+	r.wg.Wait()
+}
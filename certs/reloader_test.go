@@ -0,0 +1,206 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate with the common name, valid for ttl, and its key.
+func writeCert(t *testing.T, certFile, keyFile, name string, ttl time.Duration) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(ttl).Truncate(time.Second),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func served(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	first := writeCert(t, certFile, keyFile, "first", time.Hour)
+
+	_, err := NewReloader(Files{Certificates: []KeyPair{{Cert: filepath.Join(dir, "missing.crt"), Key: keyFile}}}, slog.New(slog.DiscardHandler), nil)
+	require.Error(t, err)
+
+	expiry := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "expiry"}, []string{"cert"})
+	r, err := NewReloader(Files{Certificates: []KeyPair{{Cert: certFile, Key: keyFile}}, RootCA: certFile, ClientAuth: tls.RequireAndVerifyClientCert}, slog.New(slog.DiscardHandler), expiry)
+	require.NoError(t, err)
+
+	assert.Equal(t, "first", served(t, r))
+
+	m := &dto.Metric{}
+	require.NoError(t, expiry.WithLabelValues(certFile).Write(m))
+	assert.Equal(t, float64(first.NotAfter.Unix()), m.GetGauge().GetValue())
+
+	// the connection settings of the returned config are kept
+	r.TLSConfig().NextProtos = []string{"h2"}
+	cfg, err := r.GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h2"}, cfg.NextProtos)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+	assert.NotNil(t, cfg.ClientCAs)
+
+	// the previous certificate is kept on error
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	require.Error(t, r.Reload())
+	assert.Equal(t, "first", served(t, r))
+
+	second := writeCert(t, certFile, keyFile, "second", time.Hour*2)
+	require.NoError(t, r.Reload())
+	assert.Equal(t, "second", served(t, r))
+
+	require.NoError(t, expiry.WithLabelValues(certFile).Write(m))
+	assert.Equal(t, float64(second.NotAfter.Unix()), m.GetGauge().GetValue())
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "first", time.Hour)
+
+	r, err := NewReloader(Files{Certificates: []KeyPair{{Cert: certFile, Key: keyFile}}}, slog.New(slog.DiscardHandler), nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Watch(0))
+	defer r.Stop()
+
+	// the files are replaced by renaming, the same way the kubernetes secrets are updated
+	tmp := t.TempDir()
+	writeCert(t, filepath.Join(tmp, "tls.crt"), filepath.Join(tmp, "tls.key"), "second", time.Hour)
+	require.NoError(t, os.Rename(filepath.Join(tmp, "tls.key"), keyFile))
+	require.NoError(t, os.Rename(filepath.Join(tmp, "tls.crt"), certFile))
+
+	require.Eventually(t, func() bool {
+		cert, _ := r.GetCertificate(&tls.ClientHelloInfo{})
+		return cert.Leaf.Subject.CommonName == "second"
+	}, time.Second*5, time.Millisecond*20)
+}
+
+func TestReloaderInterval(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "first", time.Hour)
+
+	r, err := NewReloader(Files{Certificates: []KeyPair{{Cert: certFile, Key: keyFile}}}, slog.New(slog.DiscardHandler), nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Watch(time.Millisecond*50))
+
+	writeCert(t, certFile, keyFile, "second", time.Hour)
+	require.Eventually(t, func() bool {
+		cert, _ := r.GetCertificate(&tls.ClientHelloInfo{})
+		return cert.Leaf.Subject.CommonName == "second"
+	}, time.Second*5, time.Millisecond*20)
+
+	r.Stop()
+	// stopping twice is safe
+	r.Stop()
+}
+
+func TestReloaderSNI(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, filepath.Join(dir, "default.crt"), filepath.Join(dir, "default.key"), "default.example.com", time.Hour)
+	writeCert(t, filepath.Join(dir, "api.crt"), filepath.Join(dir, "api.key"), "api.example.com", time.Hour)
+	writeCert(t, filepath.Join(dir, "wildcard.crt"), filepath.Join(dir, "wildcard.key"), "*.example.org", time.Hour)
+
+	// the inline certificate is given by its PEM material
+	writeCert(t, filepath.Join(dir, "inline.crt"), filepath.Join(dir, "inline.key"), "inline.example.com", time.Hour)
+	inlineCert, err := os.ReadFile(filepath.Join(dir, "inline.crt"))
+	require.NoError(t, err)
+	inlineKey, err := os.ReadFile(filepath.Join(dir, "inline.key"))
+	require.NoError(t, err)
+
+	expiry := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "expiry"}, []string{"cert"})
+	r, err := NewReloader(Files{Certificates: []KeyPair{
+		{Cert: filepath.Join(dir, "default.crt"), Key: filepath.Join(dir, "default.key")},
+		{Cert: filepath.Join(dir, "api.crt"), Key: filepath.Join(dir, "api.key")},
+		{Cert: filepath.Join(dir, "wildcard.crt"), Key: filepath.Join(dir, "wildcard.key")},
+		{Cert: string(inlineCert), Key: string(inlineKey)},
+	}}, slog.New(slog.DiscardHandler), expiry)
+	require.NoError(t, err)
+
+	tests := map[string]string{
+		"":                    "default.example.com",
+		"unknown.example.com": "default.example.com",
+		"API.example.com.":    "api.example.com",
+		"foo.example.org":     "*.example.org",
+		"foo.bar.example.org": "default.example.com",
+		"inline.example.com":  "inline.example.com",
+		"default.example.com": "default.example.com",
+	}
+
+	for name, expected := range tests {
+		cert, errC := r.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		require.NoError(t, errC)
+		assert.Equal(t, expected, cert.Leaf.Subject.CommonName, name)
+	}
+
+	m := &dto.Metric{}
+	require.NoError(t, expiry.WithLabelValues("inline:inline.example.com").Write(m))
+	assert.NotZero(t, m.GetGauge().GetValue())
+
+	// the inline certificates are not watched
+	require.NoError(t, r.Watch(0))
+	r.Stop()
+}
+
+func TestReadPEM(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), "test", time.Hour)
+
+	fromFile, err := ReadPEM(filepath.Join(dir, "tls.crt"))
+	require.NoError(t, err)
+	assert.False(t, IsInline(filepath.Join(dir, "tls.crt")))
+
+	assert.True(t, IsInline("\n"+string(fromFile)))
+	inline, err := ReadPEM(string(fromFile))
+	require.NoError(t, err)
+	assert.Equal(t, fromFile, inline)
+
+	_, err = ReadPEM(filepath.Join(dir, "missing.crt"))
+	require.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestConfigInitDefaults(t *testing.T) {
+	c := &Config{
+		Compressors: []string{ZstdName},
+		Rules: []*Rule{
+			{Method: "/app.Service/Get", MinSize: 1024},
+			{Method: "app.Service/Download", Compressor: encoding.Identity},
+			{Method: "app.Service/List", Compressor: ZstdName},
+		},
+	}
+	require.NoError(t, c.InitDefaults())
+	assert.Equal(t, LevelDefault, c.Level)
+	assert.Equal(t, "app.Service/Get", c.Rules[0].Method)
+	assert.Equal(t, "gzip", c.Rules[0].Compressor)
+
+	assert.Error(t, (&Config{Level: "max"}).InitDefaults())
+	assert.Error(t, (&Config{Compressors: []string{"lz4"}}).InitDefaults())
+	assert.Error(t, (&Config{Rules: []*Rule{{Method: "Get"}}}).InitDefaults())
+	assert.Error(t, (&Config{Rules: []*Rule{{Method: "app.Service/Get", MinSize: -1}}}).InitDefaults())
+	// snappy is not registered
+	assert.Error(t, (&Config{Compressors: []string{ZstdName}, Rules: []*Rule{{Method: "app.Service/Get", Compressor: SnappyName}}}).InitDefaults())
+}
+
+func TestCompressors(t *testing.T) {
+	data := []byte(strings.Repeat("roadrunner grpc ", 1024))
+
+	for _, level := range []string{LevelFastest, LevelDefault, LevelBetter, LevelBest} {
+		for _, c := range []encoding.Compressor{newZstd(level), newSnappy(level)} {
+			// the second round uses the pooled writers and readers
+			for range 2 {
+				buf := &bytes.Buffer{}
+				w, err := c.Compress(buf)
+				require.NoError(t, err)
+				_, err = w.Write(data)
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+				assert.Less(t, buf.Len(), len(data), "%s %s", c.Name(), level)
+
+				r, err := c.Decompress(buf)
+				require.NoError(t, err)
+				out, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.NoError(t, r.(io.Closer).Close())
+				assert.Equal(t, data, out, "%s %s", c.Name(), level)
+			}
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	cfg := &Config{Compressors: []string{ZstdName, SnappyName}, Level: LevelBest}
+	require.NoError(t, cfg.InitDefaults())
+	require.NoError(t, Register(cfg))
+
+	assert.NotNil(t, encoding.GetCompressor(ZstdName))
+	assert.NotNil(t, encoding.GetCompressor(SnappyName))
+
+	// restore the default gzip level
+	require.NoError(t, Register(&Config{Level: LevelDefault}))
+}
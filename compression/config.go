@@ -0,0 +1,120 @@
+// Package compression registers the additional gRPC compressors (zstd, snappy) and configures the compression
+// level and the per-method response compression rules.
+package compression
+
+import (
+	"slices"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// compression levels, shared by the compressors
+const (
+	LevelFastest string = "fastest"
+	LevelDefault string = "default"
+	LevelBetter  string = "better"
+	LevelBest    string = "best"
+)
+
+// Config configures the compressors and the response compression of the methods.
+type Config struct {
+	// Compressors are registered in addition to gzip: zstd, snappy.
+	Compressors []string `mapstructure:"compressors"`
+	// Level is the compression level of gzip, zstd and snappy: fastest, default, better or best. Default: default
+	Level string `mapstructure:"level"`
+	// Rules select the compression of the method responses.
+	Rules []*Rule `mapstructure:"rules"`
+}
+
+// Rule selects the compression of the responses of a single method. The responses are compressed with the
+// compressor the request was compressed with when there is no rule.
+type Rule struct {
+	// Method is the fully-qualified method name, e.g. `package.Service/Method`.
+	Method string `mapstructure:"method"`
+	// Compressor compresses the responses when the client accepts it, `identity` sends them uncompressed
+	// (e.g., the already compressed payloads).
+	Compressor string `mapstructure:"compressor"`
+	// MinSize is the size of the smallest response to compress, the smaller responses are sent uncompressed.
+	MinSize int `mapstructure:"min_size"`
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_compression_init")
+
+	if c.Level == "" {
+		c.Level = LevelDefault
+	}
+
+	if !slices.Contains([]string{LevelFastest, LevelDefault, LevelBetter, LevelBest}, c.Level) {
+		return errors.E(op, errors.Errorf("unknown compression level %s, should be one of: fastest, default, better, best", c.Level))
+	}
+
+	for _, name := range c.Compressors {
+		if name != ZstdName && name != SnappyName {
+			return errors.E(op, errors.Errorf("unknown compressor %s, should be one of: zstd, snappy", name))
+		}
+	}
+
+	for i, r := range c.Rules {
+		if r == nil {
+			return errors.E(op, errors.Errorf("rules[%d]: rule should not be empty", i))
+		}
+
+		var err error
+		r.Method, err = methodname.Normalize(r.Method)
+		if err != nil {
+			return errors.E(op, errors.Errorf("rules[%d]: %v", i, err))
+		}
+
+		if r.MinSize < 0 {
+			return errors.E(op, errors.Errorf("rules[%d]: min_size should not be negative, method: %s", i, r.Method))
+		}
+
+		if r.Compressor == "" {
+			r.Compressor = gzip.Name
+		}
+
+		if r.Compressor != encoding.Identity && r.Compressor != gzip.Name && !slices.Contains(c.Compressors, r.Compressor) {
+			return errors.E(op, errors.Errorf("rules[%d]: compressor %s is not registered, method: %s", i, r.Compressor, r.Method))
+		}
+	}
+
+	return nil
+}
+
+// Register registers the configured compressors and sets the gzip compression level. It should be called
+// before the server is started.
+func Register(cfg *Config) error {
+	const op = errors.Op("grpc_compression_register")
+
+	if err := gzip.SetLevel(gzipLevel(cfg.Level)); err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, name := range cfg.Compressors {
+		switch name {
+		case ZstdName:
+			encoding.RegisterCompressor(newZstd(cfg.Level))
+		case SnappyName:
+			encoding.RegisterCompressor(newSnappy(cfg.Level))
+		}
+	}
+
+	return nil
+}
+
+func gzipLevel(level string) int {
+	switch level {
+	case LevelFastest:
+		return 1
+	case LevelBetter:
+		return 7
+	case LevelBest:
+		return 9
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,76 @@
+package compression
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+)
+
+// SnappyName is the name registered for the snappy compressor, the messages use the snappy framing format.
+const SnappyName string = "snappy"
+
+type snappyCompressor struct {
+	opts    []s2.WriterOption
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func newSnappy(level string) *snappyCompressor {
+	c := &snappyCompressor{
+		opts: []s2.WriterOption{s2.WriterSnappyCompat(), s2.WriterConcurrency(1)},
+	}
+
+	switch level {
+	case LevelBetter:
+		c.opts = append(c.opts, s2.WriterBetterCompression())
+	case LevelBest:
+		c.opts = append(c.opts, s2.WriterBestCompression())
+	}
+
+	return c
+}
+
+type snappyWriter struct {
+	*s2.Writer
+	pool *sync.Pool
+}
+
+func (c *snappyCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if z, ok := c.writers.Get().(*snappyWriter); ok {
+		z.Reset(w)
+		return z, nil
+	}
+
+	return &snappyWriter{Writer: s2.NewWriter(w, c.opts...), pool: &c.writers}, nil
+}
+
+// Close flushes the stream and returns the writer to the pool.
+func (z *snappyWriter) Close() error {
+	defer z.pool.Put(z)
+	return z.Writer.Close()
+}
+
+type snappyReader struct {
+	*s2.Reader
+	pool *sync.Pool
+}
+
+func (c *snappyCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	if z, ok := c.readers.Get().(*snappyReader); ok {
+		z.Reset(r)
+		return z, nil
+	}
+
+	return &snappyReader{Reader: s2.NewReader(r), pool: &c.readers}, nil
+}
+
+// Close returns the reader to the pool.
+func (z *snappyReader) Close() error {
+	z.pool.Put(z)
+	return nil
+}
+
+func (c *snappyCompressor) Name() string {
+	return SnappyName
+}
@@ -0,0 +1,92 @@
+package compression
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ZstdName is the name registered for the zstd compressor.
+const ZstdName string = "zstd"
+
+// zstdCompressor pools the encoders and decoders, they are expensive to create.
+type zstdCompressor struct {
+	level    zstd.EncoderLevel
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func newZstd(level string) *zstdCompressor {
+	c := &zstdCompressor{}
+
+	switch level {
+	case LevelFastest:
+		c.level = zstd.SpeedFastest
+	case LevelBetter:
+		c.level = zstd.SpeedBetterCompression
+	case LevelBest:
+		c.level = zstd.SpeedBestCompression
+	default:
+		c.level = zstd.SpeedDefault
+	}
+
+	return c
+}
+
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if z, ok := c.encoders.Get().(*zstdWriter); ok {
+		z.Reset(w)
+		return z, nil
+	}
+
+	e, err := zstd.NewWriter(w, zstd.WithEncoderLevel(c.level), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return &zstdWriter{Encoder: e, pool: &c.encoders}, nil
+}
+
+// Close flushes the frame and returns the encoder to the pool.
+func (z *zstdWriter) Close() error {
+	defer z.pool.Put(z)
+	return z.Encoder.Close()
+}
+
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	if z, ok := c.decoders.Get().(*zstdReader); ok {
+		if err := z.Reset(r); err != nil {
+			c.decoders.Put(z)
+			return nil, err
+		}
+		return z, nil
+	}
+
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return &zstdReader{Decoder: d, pool: &c.decoders}, nil
+}
+
+// Close returns the decoder to the pool, the decoder itself is kept open for the reuse.
+func (z *zstdReader) Close() error {
+	z.pool.Put(z)
+	return nil
+}
+
+func (c *zstdCompressor) Name() string {
+	return ZstdName
+}
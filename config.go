@@ -9,7 +9,19 @@ import (
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/acl"
+	"github.com/roadrunner-server/grpc/v6/certs"
+	"github.com/roadrunner-server/grpc/v6/compression"
+	"github.com/roadrunner-server/grpc/v6/connect"
+	"github.com/roadrunner-server/grpc/v6/grpcweb"
+	"github.com/roadrunner-server/grpc/v6/inherit"
+	"github.com/roadrunner-server/grpc/v6/limits"
+	"github.com/roadrunner-server/grpc/v6/orca"
+	"github.com/roadrunner-server/grpc/v6/proxy"
+	"github.com/roadrunner-server/grpc/v6/proxyproto"
+	"github.com/roadrunner-server/grpc/v6/transcoding"
 	"github.com/roadrunner-server/pool/v2/pool"
 )
 
@@ -24,10 +36,14 @@ const (
 )
 
 type Config struct {
-	Listen string   `mapstructure:"listen"`
+	// Listen is the gRPC address, or a list of the addresses served by the same gRPC server. The list items are
+	// either the addresses using the top-level TLS, or the maps with the `address` and the listener's own `tls`.
+	Listen any      `mapstructure:"listen"`
 	Proto  []string `mapstructure:"proto"`
 
 	TLS *TLS `mapstructure:"tls"`
+	// listens are parsed from Listen by InitDefaults
+	listens []*Listener
 
 	// Env is environment variables passed to the http pool
 	Env          map[string]string `mapstructure:"env"`
@@ -42,17 +58,110 @@ type Config struct {
 	MaxConcurrentStreams  int64         `mapstructure:"max_concurrent_streams"`
 	PingTime              time.Duration `mapstructure:"ping_time"`
 	Timeout               time.Duration `mapstructure:"timeout"`
+
+	// FaultInjection adds synthetic delays and aborts to the proxied calls
+	FaultInjection []*proxy.FaultRule `mapstructure:"fault_injection"`
+	// Idempotency replays the stored responses for the retried calls
+	Idempotency *proxy.IdempotencyConfig `mapstructure:"idempotency"`
+	// Coalescing shares a worker execution between the identical concurrent calls
+	Coalescing *proxy.CoalescingConfig `mapstructure:"coalescing"`
+	// Cache keeps the responses of the side-effect-free methods
+	Cache *proxy.CacheConfig `mapstructure:"cache"`
+	// Async acknowledges the calls immediately and executes them in the background
+	Async *proxy.AsyncConfig `mapstructure:"async"`
+	// LongRunning returns a google.longrunning.Operation for the configured methods
+	LongRunning *proxy.LongRunningConfig `mapstructure:"long_running"`
+	// Batching groups the concurrent calls into a single worker call
+	Batching *proxy.BatchingConfig `mapstructure:"batching"`
+	// Hedging sends the slow calls of the side-effect-free methods to a second worker
+	Hedging *proxy.HedgingConfig `mapstructure:"hedging"`
+	// SizeLimits rejects the requests over the decompressed size limits of the methods
+	SizeLimits *proxy.SizeLimitConfig `mapstructure:"size_limits"`
+	// ClientIdentity maps the verified client certificates to the caller identities forwarded to the workers
+	ClientIdentity *proxy.IdentityConfig `mapstructure:"client_identity"`
+	// GrpcWeb serves the gRPC-Web (browser) clients on the same listener
+	GrpcWeb *grpcweb.Config `mapstructure:"grpc_web"`
+	// HTTPTranscoding serves the google.api.http bindings of the proxied methods on a separate HTTP listener
+	HTTPTranscoding *transcoding.Config `mapstructure:"http_transcoding"`
+	// Connect serves the Connect protocol (unary calls) on the same listener
+	Connect *connect.Config `mapstructure:"connect"`
+	// ACL checks the client addresses against the allow and deny lists of the server and the services
+	ACL *acl.Config `mapstructure:"acl"`
+	// Limits rejects the excessive connections and streams early
+	Limits *limits.Config `mapstructure:"limits"`
+	// ProxyProtocol decodes the PROXY protocol header sent by the trusted load balancers on all listeners
+	ProxyProtocol *proxyproto.Config `mapstructure:"proxy_protocol"`
+	// Handoff hands the listeners over to the successor process started with the same configuration
+	Handoff *inherit.Config `mapstructure:"handoff"`
+	// Drain configures the phases of the graceful stop
+	Drain *Drain `mapstructure:"drain"`
+	// Orca publishes the ORCA load reports for the weighted client-side load balancing
+	Orca *orca.Config `mapstructure:"orca"`
+	// Admin registers the channelz and the other grpc admin services
+	Admin *Admin `mapstructure:"admin"`
+	// Compression registers the additional compressors and selects the response compression per method
+	Compression *compression.Config `mapstructure:"compression"`
+	// HTTPProbes serves the HTTP liveness, readiness and metrics endpoints on the gRPC listeners
+	HTTPProbes *HTTPProbes `mapstructure:"http_probes"`
+}
+
+// Drain delays the stop of the server until the load balancers notice the health change, and limits the time
+// to finish the in-flight calls.
+type Drain struct {
+	// PropagationDelay is the time the server keeps serving after the health is set to NOT_SERVING
+	PropagationDelay time.Duration `mapstructure:"propagation_delay"`
+	// Timeout limits the time to finish the in-flight calls after GOAWAY, the remaining calls are canceled.
+	// Limited only by the stop timeout when zero.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Admin registers the grpc admin services (channelz) on the plugin server or on a separate listener.
+type Admin struct {
+	// Address is the separate plaintext admin listener, e.g. `127.0.0.1:9002`. The services are registered on the
+	// plugin server when empty.
+	Address string `mapstructure:"address"`
+}
+
+// HTTPProbes serves the plain HTTP requests on the gRPC port, the connections are recognized by the first bytes.
+type HTTPProbes struct {
+	// Liveness is the path of the liveness probe (Status). Default: /health
+	Liveness string `mapstructure:"liveness"`
+	// Readiness is the path of the readiness probe (Ready). Default: /ready
+	Readiness string `mapstructure:"readiness"`
+	// Metrics is the path of the plugin metrics, disabled when empty.
+	Metrics string `mapstructure:"metrics"`
+}
+
+// Listener is an address served by the gRPC server, e.g. `tcp://0.0.0.0:9443` or `unix:///var/run/grpc.sock`.
+type Listener struct {
+	Address string `mapstructure:"address"`
+	// TLS of the listener, plaintext when not set
+	TLS *TLS `mapstructure:"tls"`
 }
 
+// TLS certificates, keys and the root CA are either file paths or inline PEM material.
 type TLS struct {
 	Key      string         `mapstructure:"key"`
 	Cert     string         `mapstructure:"cert"`
 	RootCA   string         `mapstructure:"root_ca"`
 	AuthType ClientAuthType `mapstructure:"client_auth_type"`
+	// Certificates are selected by the client SNI and the names in their SANs, the default certificate is
+	// the `cert` one, or the first in the list when it is not set
+	Certificates []*Certificate `mapstructure:"certificates"`
+	// Watch reloads the certificates, the keys and the root CA when the files change
+	Watch bool `mapstructure:"watch"`
+	// ReloadInterval re-reads the files periodically, disabled when zero
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
 	// auth type
 	auth tls.ClientAuthType
 }
 
+// Certificate is an additional server certificate selected by SNI.
+type Certificate struct {
+	Key  string `mapstructure:"key"`
+	Cert string `mapstructure:"cert"`
+}
+
 func (c *Config) InitDefaults() error { //nolint:gocyclo,gocognit
 	const op = errors.Op("grpc_plugin_config")
 	if c.GrpcPool == nil {
@@ -61,9 +170,11 @@ func (c *Config) InitDefaults() error { //nolint:gocyclo,gocognit
 
 	c.GrpcPool.InitDefaults()
 
-	if !strings.Contains(c.Listen, ":") {
-		return errors.E(op, errors.Errorf("malformed grpc address, provided: %s", c.Listen))
+	listeners, err := c.parseListen()
+	if err != nil {
+		return errors.E(op, err)
 	}
+	c.listens = listeners
 
 	protos := make([]string, 0, len(c.Proto))
 	for _, path := range c.Proto {
@@ -92,49 +203,165 @@ func (c *Config) InitDefaults() error { //nolint:gocyclo,gocognit
 	c.Proto = protos
 
 	if c.EnableTLS() {
-		if _, err := os.Stat(c.TLS.Key); err != nil {
-			if stderr.Is(err, os.ErrNotExist) {
-				return errors.E(op, errors.Errorf("key file '%s' does not exists", c.TLS.Key))
+		if err := c.TLS.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	for _, l := range c.listens {
+		if !strings.Contains(l.Address, ":") {
+			return errors.E(op, errors.Errorf("malformed grpc address, provided: %s", l.Address))
+		}
+
+		// the top-level TLS is already initialized
+		if l.TLS != c.TLS && l.TLS.enabled() {
+			if err := l.TLS.InitDefaults(); err != nil {
+				return errors.E(op, err)
 			}
+		}
+	}
 
+	faults := make([]*proxy.FaultRule, 0, len(c.FaultInjection))
+	for _, rule := range c.FaultInjection {
+		if rule == nil {
+			continue
+		}
+
+		if err := rule.InitDefaults(); err != nil {
 			return errors.E(op, err)
 		}
+		faults = append(faults, rule)
+	}
+	c.FaultInjection = faults
 
-		if _, err := os.Stat(c.TLS.Cert); err != nil {
-			if stderr.Is(err, os.ErrNotExist) {
-				return errors.E(op, errors.Errorf("cert file '%s' does not exists", c.TLS.Cert))
-			}
+	if c.Idempotency != nil {
+		if err := c.Idempotency.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
 
+	if c.Coalescing != nil {
+		if err := c.Coalescing.InitDefaults(); err != nil {
 			return errors.E(op, err)
 		}
+	}
 
-		// RootCA is optional, but if provided - check it
-		if c.TLS.RootCA != "" {
-			if _, err := os.Stat(c.TLS.RootCA); err != nil {
-				if stderr.Is(err, os.ErrNotExist) {
-					return errors.E(op, errors.Errorf("root ca path provided, but root ca file '%s' does not exists", c.TLS.RootCA))
-				}
-				return errors.E(op, err)
-			}
+	if c.Cache != nil {
+		if err := c.Cache.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Async != nil {
+		if err := c.Async.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.LongRunning != nil {
+		if err := c.LongRunning.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Batching != nil {
+		if err := c.Batching.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Hedging != nil {
+		if err := c.Hedging.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.ClientIdentity != nil {
+		if err := c.ClientIdentity.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.GrpcWeb != nil {
+		if err := c.GrpcWeb.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.HTTPTranscoding != nil {
+		if err := c.HTTPTranscoding.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Connect != nil {
+		if err := c.Connect.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.ProxyProtocol != nil {
+		if err := c.ProxyProtocol.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.ACL != nil {
+		if err := c.ACL.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Drain != nil && (c.Drain.PropagationDelay < 0 || c.Drain.Timeout < 0) {
+		return errors.E(op, errors.Str("drain propagation_delay and timeout should not be negative"))
+	}
+
+	if c.Handoff != nil {
+		if err := c.Handoff.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Limits != nil {
+		if err := c.Limits.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
 
-			// auth type used only for the CA
-			switch c.TLS.AuthType {
-			case NoClientCert:
-				c.TLS.auth = tls.NoClientCert
-			case RequestClientCert:
-				c.TLS.auth = tls.RequestClientCert
-			case RequireAnyClientCert:
-				c.TLS.auth = tls.RequireAnyClientCert
-			case VerifyClientCertIfGiven:
-				c.TLS.auth = tls.VerifyClientCertIfGiven
-			case RequireAndVerifyClientCert:
-				c.TLS.auth = tls.RequireAndVerifyClientCert
-			default:
-				c.TLS.auth = tls.NoClientCert
+	if c.SizeLimits != nil {
+		if err := c.SizeLimits.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Compression != nil {
+		if err := c.Compression.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.HTTPProbes != nil {
+		if c.HTTPProbes.Liveness == "" {
+			c.HTTPProbes.Liveness = "/health"
+		}
+
+		if c.HTTPProbes.Readiness == "" {
+			c.HTTPProbes.Readiness = "/ready"
+		}
+
+		for _, path := range []string{c.HTTPProbes.Liveness, c.HTTPProbes.Readiness, c.HTTPProbes.Metrics} {
+			if path != "" && !strings.HasPrefix(path, "/") {
+				return errors.E(op, errors.Errorf("http probe path should start with /, provided: %s", path))
 			}
 		}
 	}
 
+	if c.Orca != nil {
+		if err := c.Orca.InitDefaults(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
 	// used to set max time
 	infinity := time.Duration(math.MaxInt64)
 
@@ -179,8 +406,183 @@ func (c *Config) InitDefaults() error { //nolint:gocyclo,gocognit
 }
 
 func (c *Config) EnableTLS() bool {
-	if c.TLS != nil {
-		return c.TLS.Key != "" && c.TLS.Cert != ""
+	return c.TLS.enabled()
+}
+
+// listeners returns all served addresses in the `listen` order.
+func (c *Config) listeners() []*Listener {
+	return c.listens
+}
+
+// parseListen reads `listen`: a single address or a list of the addresses and the listeners with their own TLS.
+func (c *Config) parseListen() ([]*Listener, error) {
+	switch v := c.Listen.(type) {
+	case string:
+		return []*Listener{{Address: v, TLS: c.TLS}}, nil
+	case []string:
+		listeners := make([]*Listener, 0, len(v))
+		for _, address := range v {
+			listeners = append(listeners, &Listener{Address: address, TLS: c.TLS})
+		}
+		return listeners, nil
+	case []any:
+		if len(v) == 0 {
+			return nil, errors.Str("grpc listen list is empty")
+		}
+
+		listeners := make([]*Listener, 0, len(v))
+		for _, item := range v {
+			if address, ok := item.(string); ok {
+				listeners = append(listeners, &Listener{Address: address, TLS: c.TLS})
+				continue
+			}
+
+			// the same decoding as the configuration plugin uses
+			l := &Listener{}
+			dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+				DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+				WeaklyTypedInput: true,
+				ErrorUnused:      true,
+				Result:           l,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			err = dec.Decode(item)
+			if err != nil {
+				return nil, errors.Errorf("malformed grpc listener: %v", err)
+			}
+			listeners = append(listeners, l)
+		}
+		return listeners, nil
+	case nil:
+		return nil, errors.Str("grpc listen address is not set")
+	default:
+		return nil, errors.Errorf("grpc listen should be an address or a list, provided: %T", v)
+	}
+}
+
+// recvLimit returns max_recv_msg_size lowered to the largest of the size limits, the received messages are not
+// decompressed past it.
+func (c *Config) recvLimit() int64 {
+	if c.SizeLimits == nil || c.SizeLimits.Bound() == 0 {
+		return c.MaxRecvMsgSize
+	}
+
+	return min(c.MaxRecvMsgSize, int64(c.SizeLimits.Bound()))
+}
+
+// anyTLS reports whether any of the listeners uses TLS.
+func (c *Config) anyTLS() bool {
+	for _, l := range c.listeners() {
+		if l.TLS.enabled() {
+			return true
+		}
 	}
+
 	return false
 }
+
+func (t *TLS) enabled() bool {
+	return t != nil && (t.Key != "" && t.Cert != "" || len(t.Certificates) > 0)
+}
+
+// keyPairs returns the certificates in the order of selection, the default one first.
+func (t *TLS) keyPairs() []certs.KeyPair {
+	pairs := make([]certs.KeyPair, 0, len(t.Certificates)+1)
+	if t.Key != "" && t.Cert != "" {
+		pairs = append(pairs, certs.KeyPair{Cert: t.Cert, Key: t.Key})
+	}
+
+	for _, c := range t.Certificates {
+		pairs = append(pairs, certs.KeyPair{Cert: c.Cert, Key: c.Key})
+	}
+
+	return pairs
+}
+
+// InitDefaults checks and parses the certificates, the keys and the root CA.
+func (t *TLS) InitDefaults() error {
+	const op = errors.Op("grpc_plugin_tls_config")
+
+	for i, c := range t.Certificates {
+		if c == nil || c.Key == "" || c.Cert == "" {
+			return errors.E(op, errors.Errorf("certificates[%d]: both cert and key should be set", i))
+		}
+	}
+
+	for _, kp := range t.keyPairs() {
+		if err := checkFile(kp.Key, "key"); err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := checkFile(kp.Cert, "cert"); err != nil {
+			return errors.E(op, err)
+		}
+
+		if _, err := certs.LoadKeyPair(kp); err != nil {
+			return errors.E(op, errors.Errorf("invalid certificate '%s': %v", pemSource(kp.Cert), err))
+		}
+	}
+
+	// RootCA is optional, but if provided - check it
+	if t.RootCA != "" {
+		if !certs.IsInline(t.RootCA) {
+			if _, err := os.Stat(t.RootCA); err != nil {
+				if stderr.Is(err, os.ErrNotExist) {
+					return errors.E(op, errors.Errorf("root ca path provided, but root ca file '%s' does not exists", t.RootCA))
+				}
+				return errors.E(op, err)
+			}
+		}
+
+		if _, err := certs.LoadCertPool(t.RootCA); err != nil {
+			return errors.E(op, errors.Errorf("invalid root ca '%s': %v", pemSource(t.RootCA), err))
+		}
+
+		// auth type used only for the CA
+		switch t.AuthType {
+		case NoClientCert:
+			t.auth = tls.NoClientCert
+		case RequestClientCert:
+			t.auth = tls.RequestClientCert
+		case RequireAnyClientCert:
+			t.auth = tls.RequireAnyClientCert
+		case VerifyClientCertIfGiven:
+			t.auth = tls.VerifyClientCertIfGiven
+		case RequireAndVerifyClientCert:
+			t.auth = tls.RequireAndVerifyClientCert
+		default:
+			t.auth = tls.NoClientCert
+		}
+	}
+
+	return nil
+}
+
+// checkFile checks the file exists, the inline PEM material is checked when parsed.
+func checkFile(value, kind string) error {
+	if certs.IsInline(value) {
+		return nil
+	}
+
+	if _, err := os.Stat(value); err != nil {
+		if stderr.Is(err, os.ErrNotExist) {
+			return errors.Errorf("%s file '%s' does not exists", kind, value)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// pemSource is the value for the error messages, the inline PEM material is not printed.
+func pemSource(value string) string {
+	if certs.IsInline(value) {
+		return "inline"
+	}
+
+	return value
+}
@@ -1,10 +1,13 @@
 package grpc
 
 import (
+	"crypto/tls"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const separator = string(filepath.Separator)
@@ -72,3 +75,93 @@ func TestInitDefaults(t *testing.T) {
 	c.Proto = []string{"[[[error"}
 	assert.Error(t, c.InitDefaults())
 }
+
+func TestInitDefaultsListeners(t *testing.T) {
+	c := Config{Listen: []any{"unix:///tmp/grpc.sock"}}
+	assert.NoError(t, c.InitDefaults())
+	assert.Len(t, c.listeners(), 1)
+	assert.False(t, c.anyTLS())
+
+	// the addresses use the top-level TLS, the maps their own
+	c.TLS = &TLS{Cert: "missing.crt", Key: "missing.key"}
+	c.Listen = []any{"127.0.0.1:9001"}
+	assert.Error(t, c.InitDefaults())
+
+	c.TLS = nil
+	c.Listen = []any{
+		"127.0.0.1:9001",
+		map[string]any{"address": "127.0.0.1:9443", "tls": map[string]any{"cert": "missing.crt", "key": "missing.key"}},
+	}
+	assert.Error(t, c.InitDefaults())
+
+	c.Listen = []any{"127.0.0.1:9001", map[string]any{"address": "unix:///tmp/grpc.sock"}}
+	assert.NoError(t, c.InitDefaults())
+	require.Len(t, c.listeners(), 2)
+	assert.Equal(t, "127.0.0.1:9001", c.listeners()[0].Address)
+	assert.Equal(t, "unix:///tmp/grpc.sock", c.listeners()[1].Address)
+
+	c.Listen = []any{map[string]any{"address": "127.0.0.1:9001", "unknown": true}}
+	assert.Error(t, c.InitDefaults())
+
+	c.Listen = []any{"localhost"}
+	assert.Error(t, c.InitDefaults())
+
+	c.Listen = []any{}
+	assert.Error(t, c.InitDefaults())
+
+	c.Listen = 9001
+	assert.Error(t, c.InitDefaults())
+}
+
+func TestInitDefaultsHTTPProbes(t *testing.T) {
+	c := Config{Listen: "127.0.0.1:9001", HTTPProbes: &HTTPProbes{Metrics: "/metrics"}}
+	assert.NoError(t, c.InitDefaults())
+	assert.Equal(t, "/health", c.HTTPProbes.Liveness)
+	assert.Equal(t, "/ready", c.HTTPProbes.Readiness)
+
+	c.HTTPProbes.Metrics = "metrics"
+	assert.Error(t, c.InitDefaults())
+}
+
+func TestTLSInitDefaults(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "localhost")
+	apiCert, apiKey := writeCert(t, dir, "api.localhost")
+
+	inlineCert, err := os.ReadFile(apiCert)
+	require.NoError(t, err)
+	inlineKey, err := os.ReadFile(apiKey)
+	require.NoError(t, err)
+
+	c := &TLS{
+		Cert:   certFile,
+		Key:    keyFile,
+		RootCA: string(inlineCert),
+		Certificates: []*Certificate{
+			{Cert: string(inlineCert), Key: string(inlineKey)},
+		},
+		AuthType: RequireAndVerifyClientCert,
+	}
+	require.NoError(t, c.InitDefaults())
+	assert.Equal(t, tls.RequireAndVerifyClientCert, c.auth)
+	assert.Len(t, c.keyPairs(), 2)
+	assert.Equal(t, certFile, c.keyPairs()[0].Cert)
+
+	// the first certificate in the list is the default one
+	c = &TLS{Certificates: []*Certificate{{Cert: apiCert, Key: apiKey}}}
+	assert.True(t, c.enabled())
+	require.NoError(t, c.InitDefaults())
+
+	// the key does not match the certificate
+	c = &TLS{Certificates: []*Certificate{{Cert: certFile, Key: string(inlineKey)}}}
+	assert.ErrorContains(t, c.InitDefaults(), "invalid certificate")
+
+	c = &TLS{Certificates: []*Certificate{{Cert: certFile}}}
+	assert.ErrorContains(t, c.InitDefaults(), "both cert and key should be set")
+
+	c = &TLS{Cert: certFile, Key: keyFile, RootCA: "-----BEGIN CERTIFICATE-----\ngarbage\n-----END CERTIFICATE-----\n"}
+	assert.ErrorContains(t, c.InitDefaults(), "invalid root ca 'inline'")
+
+	c = &TLS{Cert: filepath.Join(dir, "missing.crt"), Key: keyFile}
+	assert.ErrorContains(t, c.InitDefaults(), "does not exists")
+}
@@ -0,0 +1,29 @@
+// Package connect serves the unary calls of the proxied services over the Connect protocol
+// (https://connectrpc.com/docs/protocol), so the connect-web clients could call the server without a gateway.
+package connect
+
+import (
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Config configures the Connect protocol support on the plugin listener.
+type Config struct {
+	// MaxTimeout caps the timeout requested by the clients in the Connect-Timeout-Ms header. Not capped when zero.
+	MaxTimeout time.Duration `mapstructure:"max_timeout"`
+	// EmitUnpopulated renders the fields with the zero values in the JSON responses.
+	EmitUnpopulated bool `mapstructure:"emit_unpopulated"`
+	// DiscardUnknown ignores the unknown fields in the JSON requests.
+	DiscardUnknown bool `mapstructure:"discard_unknown"`
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_connect_init")
+
+	if c.MaxTimeout < 0 {
+		return errors.E(op, errors.Str("max_timeout should not be negative"))
+	}
+
+	return nil
+}
@@ -0,0 +1,393 @@
+package connect
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	stderr "errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jhump/protoreflect/v2/protoresolve"
+	"github.com/roadrunner-server/grpc/v6/api"
+	"github.com/roadrunner-server/grpc/v6/internal/gateway"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	contentTypeProto string = "application/proto"
+	contentTypeJSON  string = "application/json"
+
+	headerTimeout         string = "Connect-Timeout-Ms"
+	headerProtocolVersion string = "Connect-Protocol-Version"
+	// prefix of the trailers, the unary responses send them as headers
+	trailerPrefix string = "Trailer-"
+
+	// the timeout is a positive integer of at most 10 digits
+	maxTimeoutDigits int = 10
+)
+
+// Handler serves the Connect unary requests by calling the methods through the invoker. Other requests are
+// passed to the wrapped handler as is.
+type Handler struct {
+	cfg      *Config
+	log      *slog.Logger
+	invoker  api.Invoker
+	registry *protoresolve.Registry
+	next     http.Handler
+	maxBody  int64
+
+	marshal   protojson.MarshalOptions
+	unmarshal protojson.UnmarshalOptions
+	binary    proto.UnmarshalOptions
+}
+
+// NewHandler wraps the handler. The registry resolves the message descriptors of the JSON requests, only the
+// binary (application/proto) requests are served when it is nil. The request bodies are limited to maxBody bytes.
+func NewHandler(cfg *Config, invoker api.Invoker, registry *protoresolve.Registry, maxBody int64, log *slog.Logger, next http.Handler) *Handler {
+	h := &Handler{
+		cfg:      cfg,
+		log:      log,
+		invoker:  invoker,
+		registry: registry,
+		next:     next,
+		maxBody:  maxBody,
+		marshal: protojson.MarshalOptions{
+			EmitUnpopulated: cfg.EmitUnpopulated,
+		},
+		unmarshal: protojson.UnmarshalOptions{
+			DiscardUnknown: cfg.DiscardUnknown,
+		},
+	}
+
+	if registry != nil {
+		types := registry.AsTypeResolver()
+		h.marshal.Resolver = types
+		h.unmarshal.Resolver = types
+		h.binary.Resolver = types
+	}
+
+	return h
+}
+
+// IsConnectRequest reports whether the request is a Connect unary request.
+func IsConnectRequest(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+
+	return codecName(r.Header.Get("Content-Type")) != ""
+}
+
+// codecName returns the codec of the content type, `proto` or `json`, or an empty string for other content types.
+func codecName(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch mt {
+	case contentTypeProto:
+		return "proto"
+	case contentTypeJSON:
+		return "json"
+	default:
+		return ""
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !IsConnectRequest(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	codec := codecName(r.Header.Get("Content-Type"))
+	method := r.URL.Path
+
+	ctx, cancel, err := h.context(r)
+	if err != nil {
+		h.writeError(w, err, nil, nil)
+		return
+	}
+	defer cancel()
+
+	in, err := h.request(w, r, codec, method)
+	if err != nil {
+		h.writeError(w, err, nil, nil)
+		return
+	}
+
+	out, header, trailer, err := h.invoker.Invoke(ctx, method, in)
+	if err != nil {
+		h.writeError(w, err, header, trailer)
+		return
+	}
+
+	if codec == "json" {
+		out, err = h.toJSON(method, out)
+		if err != nil {
+			h.writeError(w, err, header, trailer)
+			return
+		}
+	}
+
+	writeMetadata(w.Header(), "", header)
+	writeMetadata(w.Header(), trailerPrefix, trailer)
+
+	w.Header().Set("Content-Type", "application/"+codec)
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
+
+// context creates the call context with the incoming metadata, the peer and the requested timeout.
+func (h *Handler) context(r *http.Request) (context.Context, context.CancelFunc, error) {
+	if v := r.Header.Get(headerProtocolVersion); v != "" && v != "1" {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "%s must be 1: got %q", headerProtocolVersion, v)
+	}
+
+	md, err := gateway.Metadata(r.Header, metadataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	pr := &peer.Peer{Addr: gateway.Addr(r.RemoteAddr)}
+	if r.TLS != nil {
+		// the same auth info as the gRPC server gives to the calls over TLS, with the verified client certificate
+		pr.AuthInfo = credentials.TLSInfo{State: *r.TLS, CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity}}
+	}
+	ctx = peer.NewContext(ctx, pr)
+
+	v := r.Header.Get(headerTimeout)
+	if v == "" {
+		if h.cfg.MaxTimeout > 0 {
+			ctx, cancel := context.WithTimeout(ctx, h.cfg.MaxTimeout)
+			return ctx, cancel, nil
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms < 0 || len(v) > maxTimeoutDigits {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "malformed %s: %q", headerTimeout, v)
+	}
+
+	timeout := time.Duration(ms) * time.Millisecond
+	if h.cfg.MaxTimeout > 0 && timeout > h.cfg.MaxTimeout {
+		timeout = h.cfg.MaxTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, nil
+}
+
+// request reads the request message, the JSON messages are converted to the binary form.
+func (h *Handler) request(w http.ResponseWriter, r *http.Request, codec, method string) ([]byte, error) {
+	var body io.Reader = http.MaxBytesReader(w, r.Body, h.maxBody)
+
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "malformed gzip body: %v", err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+
+		// the decompressed message is limited too
+		body = io.LimitReader(gz, h.maxBody+1)
+	default:
+		w.Header().Set("Accept-Encoding", "gzip")
+		return nil, status.Errorf(codes.Unimplemented, "unsupported content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if stderr.As(err, &mbe) {
+			return nil, status.Errorf(codes.ResourceExhausted, "request message is larger than %d bytes", h.maxBody)
+		}
+
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if int64(len(data)) > h.maxBody {
+		return nil, status.Errorf(codes.ResourceExhausted, "request message is larger than %d bytes", h.maxBody)
+	}
+
+	if codec == "proto" {
+		return data, nil
+	}
+
+	md, err := h.method(method)
+	if err != nil {
+		return nil, err
+	}
+
+	in := dynamicpb.NewMessage(md.Input())
+	err = h.unmarshal.Unmarshal(data, in)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "malformed request message: %v", err)
+	}
+
+	out, err := proto.Marshal(in)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return out, nil
+}
+
+// toJSON converts the binary response message to JSON.
+func (h *Handler) toJSON(method string, out []byte) ([]byte, error) {
+	md, err := h.method(method)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(md.Output())
+	err = h.binary.Unmarshal(out, msg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "malformed worker response: %v", err)
+	}
+
+	data, err := h.marshal.Marshal(msg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return data, nil
+}
+
+// method resolves the descriptor of the method by its path, /package.Service/Method.
+func (h *Handler) method(method string) (protoreflect.MethodDescriptor, error) {
+	if h.registry == nil {
+		return nil, status.Error(codes.Unimplemented, "JSON requests require the proto descriptors, use application/proto")
+	}
+
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+
+	d, err := h.registry.FindDescriptorByName(protoreflect.FullName(service + "." + name))
+	if err != nil {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", strings.TrimPrefix(method, "/"))
+	}
+
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", strings.TrimPrefix(method, "/"))
+	}
+
+	return md, nil
+}
+
+// wireError is the JSON form of the Connect error.
+type wireError struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message,omitempty"`
+	Details []*wireDetail `json:"details,omitempty"`
+}
+
+type wireDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// writeError renders the error in the Connect JSON form, the response metadata is sent as headers.
+func (h *Handler) writeError(w http.ResponseWriter, err error, header, trailer metadata.MD) {
+	st := status.Convert(err)
+	name, code := errorCode(st.Code())
+
+	we := &wireError{
+		Code:    name,
+		Message: st.Message(),
+	}
+
+	for _, d := range st.Proto().GetDetails() {
+		we.Details = append(we.Details, &wireDetail{
+			Type:  strings.TrimPrefix(d.GetTypeUrl(), "type.googleapis.com/"),
+			Value: base64.RawStdEncoding.EncodeToString(d.GetValue()),
+		})
+	}
+
+	data, errM := json.Marshal(we)
+	if errM != nil {
+		h.log.Warn("failed to render the connect error", "error", errM)
+		data = []byte(`{"code":"internal"}`)
+	}
+
+	writeMetadata(w.Header(), "", header)
+	writeMetadata(w.Header(), trailerPrefix, trailer)
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+// metadataKey maps the request header to the metadata key, the protocol headers are skipped. The Connect protocol
+// forwards the other headers as the call metadata.
+func metadataKey(header string) string {
+	switch header {
+	case "Content-Type", "Content-Encoding", "Accept-Encoding", headerTimeout, headerProtocolVersion:
+		return ""
+	default:
+		return header
+	}
+}
+
+// writeMetadata sets the metadata as the headers with the prefix, the binary values are base64-encoded.
+func writeMetadata(h http.Header, prefix string, md metadata.MD) {
+	for k, v := range md {
+		for _, vv := range v {
+			if strings.HasSuffix(k, "-bin") {
+				vv = base64.RawStdEncoding.EncodeToString([]byte(vv))
+			}
+			h.Add(prefix+k, vv)
+		}
+	}
+}
+
+// errorCode returns the Connect name of the code (snake_case) and the HTTP status of the error response.
+func errorCode(code codes.Code) (string, int) {
+	// OK and the codes out of the gRPC range are not valid errors
+	if code == codes.OK || code > codes.Unauthenticated {
+		return "unknown", http.StatusInternalServerError
+	}
+
+	var name bytes.Buffer
+	for i, c := range code.String() {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				name.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		name.WriteRune(c)
+	}
+
+	return name.String(), gateway.HTTPStatus(code)
+}
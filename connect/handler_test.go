@@ -0,0 +1,264 @@
+package connect
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhump/protoreflect/v2/protoresolve"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+// fakeInvoker records the call and responds with the SERVING status or the error.
+type fakeInvoker struct {
+	method   string
+	in       []byte
+	md       metadata.MD
+	deadline time.Duration
+	peer     *peer.Peer
+	err      error
+}
+
+func (f *fakeInvoker) Invoke(ctx context.Context, method string, in []byte) ([]byte, metadata.MD, metadata.MD, error) {
+	f.method, f.in = method, in
+	f.md, _ = metadata.FromIncomingContext(ctx)
+	f.peer, _ = peer.FromContext(ctx)
+	if d, ok := ctx.Deadline(); ok {
+		f.deadline = time.Until(d)
+	}
+
+	header := metadata.Pairs("x-header", "h", "x-data-bin", "\x01\x02")
+	trailer := metadata.Pairs("x-trailer", "t")
+	if f.err != nil {
+		return nil, header, trailer, f.err
+	}
+
+	out, err := proto.Marshal(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+	return out, header, trailer, err
+}
+
+func newTestHandler(t *testing.T, cfg *Config, inv *fakeInvoker, withRegistry bool) *Handler {
+	t.Helper()
+
+	var reg *protoresolve.Registry
+	if withRegistry {
+		reg = &protoresolve.Registry{}
+		require.NoError(t, reg.RegisterFile(grpc_health_v1.File_grpc_health_v1_health_proto))
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	return NewHandler(cfg, inv, reg, 1024, slog.New(slog.DiscardHandler), next)
+}
+
+func TestConnectProto(t *testing.T) {
+	inv := &fakeInvoker{}
+	h := newTestHandler(t, &Config{}, inv, false)
+
+	req, err := proto.Marshal(&grpc_health_v1.HealthCheckRequest{Service: "app"})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, checkMethod, bytes.NewReader(req))
+	r.Header.Set("Content-Type", "application/proto")
+	r.Header.Set("Connect-Protocol-Version", "1")
+	r.Header.Set("Connect-Timeout-Ms", "1500")
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("X-Data-Bin", base64.RawStdEncoding.EncodeToString([]byte("\x03\x04")))
+	r.Header.Set("Grpc-Timeout", "1S")
+	r.Header.Set("Cookie", "session=1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, checkMethod, inv.method)
+	assert.Equal(t, req, inv.in)
+	assert.Equal(t, []string{"Bearer token"}, inv.md.Get("authorization"))
+	assert.Equal(t, []string{"\x03\x04"}, inv.md.Get("x-data-bin"))
+	assert.Empty(t, inv.md.Get("connect-timeout-ms"))
+	assert.Empty(t, inv.md.Get("grpc-timeout"))
+	assert.Empty(t, inv.md.Get("cookie"))
+	assert.Greater(t, inv.deadline, time.Second)
+	assert.LessOrEqual(t, inv.deadline, time.Millisecond*1500)
+
+	assert.Equal(t, "application/proto", w.Header().Get("Content-Type"))
+	assert.Equal(t, "h", w.Header().Get("X-Header"))
+	assert.Equal(t, base64.RawStdEncoding.EncodeToString([]byte("\x01\x02")), w.Header().Get("X-Data-Bin"))
+	assert.Equal(t, "t", w.Header().Get("Trailer-X-Trailer"))
+
+	resp := &grpc_health_v1.HealthCheckResponse{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), resp))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	// JSON requires the descriptors
+	r = httptest.NewRequest(http.MethodPost, checkMethod, strings.NewReader(`{"service":"app"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestConnectTLS(t *testing.T) {
+	inv := &fakeInvoker{}
+	h := newTestHandler(t, &Config{}, inv, false)
+
+	r := httptest.NewRequest(http.MethodPost, checkMethod, bytes.NewReader(nil))
+	r.Header.Set("Content-Type", "application/proto")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	require.NotNil(t, inv.peer)
+	assert.Nil(t, inv.peer.AuthInfo)
+
+	// the client certificate is passed the same way as by the gRPC server
+	r = httptest.NewRequest(http.MethodPost, checkMethod, bytes.NewReader(nil))
+	r.Header.Set("Content-Type", "application/proto")
+	r.TLS = &tls.ConnectionState{ServerName: "localhost"}
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	info, ok := inv.peer.AuthInfo.(credentials.TLSInfo)
+	require.True(t, ok)
+	assert.Equal(t, "localhost", info.State.ServerName)
+	assert.Equal(t, "tls", info.AuthType())
+}
+
+func TestConnectJSON(t *testing.T) {
+	inv := &fakeInvoker{}
+	h := newTestHandler(t, &Config{MaxTimeout: time.Second}, inv, true)
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	_, err := gz.Write([]byte(`{"service":"app"}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	r := httptest.NewRequest(http.MethodPost, checkMethod, &body)
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Header.Set("Content-Encoding", "gzip")
+	r.Header.Set("Connect-Timeout-Ms", "60000")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"status":"SERVING"}`, w.Body.String())
+	// the timeout is capped
+	assert.LessOrEqual(t, inv.deadline, time.Second)
+
+	in := &grpc_health_v1.HealthCheckRequest{}
+	require.NoError(t, proto.Unmarshal(inv.in, in))
+	assert.Equal(t, "app", in.GetService())
+
+	r = httptest.NewRequest(http.MethodPost, checkMethod, strings.NewReader(`{"unknown":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestConnectErrors(t *testing.T) {
+	st, err := status.New(codes.NotFound, "no such service").WithDetails(&errdetails.ErrorInfo{Reason: "MISSING"})
+	require.NoError(t, err)
+
+	inv := &fakeInvoker{err: st.Err()}
+	h := newTestHandler(t, &Config{}, inv, true)
+
+	r := httptest.NewRequest(http.MethodPost, checkMethod, strings.NewReader(`{}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "h", w.Header().Get("X-Header"))
+	assert.Equal(t, "t", w.Header().Get("Trailer-X-Trailer"))
+
+	var we wireError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &we))
+	assert.Equal(t, "not_found", we.Code)
+	assert.Equal(t, "no such service", we.Message)
+	require.Len(t, we.Details, 1)
+	assert.Equal(t, "google.rpc.ErrorInfo", we.Details[0].Type)
+
+	value, err := base64.RawStdEncoding.DecodeString(we.Details[0].Value)
+	require.NoError(t, err)
+	info := &errdetails.ErrorInfo{}
+	require.NoError(t, proto.Unmarshal(value, info))
+	assert.Equal(t, "MISSING", info.GetReason())
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		body   string
+		status int
+		code   string
+	}{
+		{"timeout", map[string]string{"Connect-Timeout-Ms": "soon"}, "", http.StatusBadRequest, "invalid_argument"},
+		{"long timeout", map[string]string{"Connect-Timeout-Ms": "12345678901"}, "", http.StatusBadRequest, "invalid_argument"},
+		{"version", map[string]string{"Connect-Protocol-Version": "2"}, "", http.StatusBadRequest, "invalid_argument"},
+		{"encoding", map[string]string{"Content-Encoding": "br"}, "", http.StatusNotImplemented, "unimplemented"},
+		{"size", nil, strings.Repeat("a", 2048), http.StatusTooManyRequests, "resource_exhausted"},
+	}
+
+	for _, tt := range tests {
+		r = httptest.NewRequest(http.MethodPost, checkMethod, strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/proto")
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.status, w.Code, tt.name)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &we), tt.name)
+		assert.Equal(t, tt.code, we.Code, tt.name)
+	}
+}
+
+func TestConnectPassthrough(t *testing.T) {
+	h := newTestHandler(t, &Config{}, &fakeInvoker{}, false)
+
+	r := httptest.NewRequest(http.MethodPost, checkMethod, nil)
+	r.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, checkMethod, nil)
+	r.Header.Set("Content-Type", "application/proto")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func TestErrorCode(t *testing.T) {
+	name, code := errorCode(codes.DeadlineExceeded)
+	assert.Equal(t, "deadline_exceeded", name)
+	assert.Equal(t, http.StatusGatewayTimeout, code)
+
+	name, code = errorCode(codes.Canceled)
+	assert.Equal(t, "canceled", name)
+	assert.Equal(t, 499, code)
+
+	name, _ = errorCode(codes.Code(42))
+	assert.Equal(t, "unknown", name)
+}
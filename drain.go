@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// drain stops the servers in phases: the health is set to NOT_SERVING, the servers keep serving for the
+// propagation delay, then GOAWAY is sent and the in-flight calls are awaited up to the drain timeout. The calls
+// still need the workers, so it is not made under the plugin lock.
+func (p *Plugin) drain(ctx context.Context) {
+	p.mu.RLock()
+	server, httpSrv, transcodingSrv, probesSrv, adminSrv, health := p.server, p.httpSrv, p.transcodingSrv, p.probesSrv, p.adminSrv, p.healthServer
+	p.mu.RUnlock()
+
+	var delay, timeout time.Duration
+	if p.config.Drain != nil {
+		delay, timeout = p.config.Drain.PropagationDelay, p.config.Drain.Timeout
+	}
+
+	if health != nil {
+		health.SetServingStatus(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	}
+	p.log.Info("drain: health was set to NOT_SERVING", "in_flight", p.inFlight.Load(), "propagation_delay", delay)
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	p.log.Info("drain: sending GOAWAY, waiting for the in-flight calls", "in_flight", p.inFlight.Load())
+
+	if transcodingSrv != nil {
+		err := transcodingSrv.Shutdown(ctx)
+		if err != nil {
+			p.log.Error("http transcoding server was not stopped gracefully", "error", err)
+			_ = transcodingSrv.Close()
+		}
+	}
+
+	if probesSrv != nil {
+		err := probesSrv.Shutdown(ctx)
+		if err != nil {
+			_ = probesSrv.Close()
+		}
+	}
+
+	// waits for the in-flight requests, the gRPC server is not serving the listener in this mode
+	if httpSrv != nil {
+		err := httpSrv.Shutdown(ctx)
+		if err != nil {
+			p.log.Error("http server was not stopped gracefully", "error", err)
+			_ = httpSrv.Close()
+		}
+	}
+
+	if server != nil {
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			p.log.Warn("drain: deadline exceeded, canceling the in-flight calls", "in_flight", p.inFlight.Load())
+			server.Stop()
+			<-stopped
+		}
+	}
+
+	// the admin server is stopped last, so the stuck connections could be inspected during the drain
+	if adminSrv != nil {
+		adminSrv.Stop()
+	}
+
+	p.log.Info("drain: servers were stopped", "in_flight", p.inFlight.Load())
+}
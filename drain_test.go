@@ -0,0 +1,171 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/grpc/v6/proxy"
+	"github.com/roadrunner-server/pool/v2/payload"
+	staticPool "github.com/roadrunner-server/pool/v2/pool/static_pool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startDrainServer serves the health service, the calls with the `x-slow` metadata wait for the release.
+func startDrainServer(t *testing.T, drain *Drain, release chan struct{}) (*Plugin, grpc_health_v1.HealthClient) {
+	t.Helper()
+
+	cfg := &Config{Listen: "127.0.0.1:0", Drain: drain}
+	require.NoError(t, cfg.InitDefaults())
+
+	p := &Plugin{
+		config:          cfg,
+		mu:              &sync.RWMutex{},
+		log:             slog.New(slog.DiscardHandler),
+		queueSize:       prometheus.NewGauge(prometheus.GaugeOpts{Name: "q"}),
+		requestCounter:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "c"}, []string{"grpc_method", "status_code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "d"}, []string{"grpc_method"}),
+	}
+
+	slow := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("x-slow")) > 0 {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+		}
+		return handler(ctx, req)
+	}
+
+	p.server = grpc.NewServer(grpc.ChainUnaryInterceptor(p.interceptor, slow))
+	p.healthServer = NewHeathServer(p, p.log)
+	p.healthServer.RegisterServer(p.server)
+
+	listeners, err := p.listen()
+	require.NoError(t, err)
+	p.serve(listeners, make(chan error, 1))
+	t.Cleanup(p.server.Stop)
+
+	conn, err := grpc.NewClient(listeners[0].Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return p, grpc_health_v1.NewHealthClient(conn)
+}
+
+func TestDrain(t *testing.T) {
+	release := make(chan struct{})
+	p, client := startDrainServer(t, &Drain{PropagationDelay: time.Millisecond * 300, Timeout: time.Second * 5}, release)
+
+	slowErr := make(chan error, 1)
+	go func() {
+		_, err := client.Check(metadata.AppendToOutgoingContext(context.Background(), "x-slow", "1"), &grpc_health_v1.HealthCheckRequest{})
+		slowErr <- err
+	}()
+	require.Eventually(t, func() bool { return p.inFlight.Load() == 1 }, time.Second, time.Millisecond*10)
+
+	drained := make(chan struct{})
+	start := time.Now()
+	go func() {
+		p.drain(context.Background())
+		close(drained)
+	}()
+
+	// the server keeps serving during the propagation delay, reporting NOT_SERVING
+	require.Eventually(t, func() bool {
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		return err == nil && resp.GetStatus() == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}, time.Second, time.Millisecond*10)
+
+	// the in-flight call is awaited after GOAWAY
+	time.Sleep(time.Millisecond * 400)
+	select {
+	case <-drained:
+		t.Fatal("the drain should wait for the in-flight call")
+	default:
+	}
+
+	close(release)
+	<-drained
+	require.NoError(t, <-slowErr)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*300)
+	assert.Equal(t, int64(0), p.inFlight.Load())
+}
+
+func TestDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	p, client := startDrainServer(t, &Drain{Timeout: time.Millisecond * 200}, release)
+
+	slowErr := make(chan error, 1)
+	go func() {
+		_, err := client.Check(metadata.AppendToOutgoingContext(context.Background(), "x-slow", "1"), &grpc_health_v1.HealthCheckRequest{})
+		slowErr <- err
+	}()
+	require.Eventually(t, func() bool { return p.inFlight.Load() == 1 }, time.Second, time.Millisecond*10)
+
+	// the remaining calls are canceled at the deadline
+	start := time.Now()
+	p.drain(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+
+	err := <-slowErr
+	require.Error(t, err)
+	assert.Contains(t, []codes.Code{codes.Canceled, codes.Unavailable}, status.Code(err))
+}
+
+// blockingPool takes a while to fail the worker calls.
+type blockingPool struct {
+	fakeStatusPool
+	calls atomic.Int32
+}
+
+func (b *blockingPool) Exec(context.Context, *payload.Payload, chan struct{}) (chan *staticPool.PExec, error) {
+	b.calls.Add(1)
+	time.Sleep(time.Millisecond * 20)
+	return nil, status.Error(codes.Unavailable, "no free workers")
+}
+
+func TestStopDrainsAsyncQueue(t *testing.T) {
+	cfg := &Config{}
+	pool := &blockingPool{}
+	p := &Plugin{config: cfg, mu: &sync.RWMutex{}, log: slog.New(slog.DiscardHandler)}
+
+	retries := 0
+	asyncCfg := &proxy.AsyncConfig{Methods: []string{"app.Service/Ingest"}, Retries: &retries}
+	require.NoError(t, asyncCfg.InitDefaults())
+
+	var err error
+	p.async, err = proxy.NewAsyncDispatcher(asyncCfg, p.log)
+	require.NoError(t, err)
+
+	px := proxy.NewProxy("app.Service", "", p.log, pool, p.mu, propagation.TraceContext{})
+	px.RegisterMethod("Ingest")
+	px.SetAsync(p.async)
+	inv := newInvoker([]*proxy.Proxy{px}, nil)
+
+	for range 5 {
+		_, _, _, err = inv.Invoke(context.Background(), "/app.Service/Ingest", []byte("event"))
+		require.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// the queued calls take the plugin lock, Stop must not hold it while waiting for them
+	require.NoError(t, p.Stop(ctx))
+	assert.Equal(t, int32(5), pool.calls.Load())
+}
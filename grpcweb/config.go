@@ -0,0 +1,78 @@
+// Package grpcweb translates the gRPC-Web requests into the regular gRPC requests served by grpc.Server.ServeHTTP.
+package grpcweb
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Config configures the gRPC-Web support and CORS for the browser clients.
+type Config struct {
+	// AllowedOrigins are the origins allowed to make the cross-origin requests, `*` allows any origin.
+	// A single `*` wildcard is supported in a pattern, e.g. `https://*.example.com`.
+	// The cross-origin requests are not allowed when empty.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// AllowedHeaders are the additional request headers (metadata) allowed in the cross-origin requests.
+	AllowedHeaders []string `mapstructure:"allowed_headers"`
+	// ExposedHeaders are the additional response headers (metadata) exposed to the browser.
+	ExposedHeaders []string `mapstructure:"exposed_headers"`
+	// AllowCredentials allows the cookies and the authorization headers in the cross-origin requests.
+	AllowCredentials bool `mapstructure:"allow_credentials"`
+	// MaxAge is the time the preflight response might be cached for. Defaults to 10m.
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_web_init")
+
+	for _, o := range c.AllowedOrigins {
+		if strings.Count(o, "*") > 1 {
+			return errors.E(op, errors.Errorf("only a single wildcard is supported in the origin: %s", o))
+		}
+	}
+
+	if c.AllowCredentials {
+		for _, o := range c.AllowedOrigins {
+			if o == "*" {
+				return errors.E(op, errors.Str("the `*` origin could not be used with allow_credentials"))
+			}
+		}
+	}
+
+	for i := range c.AllowedHeaders {
+		c.AllowedHeaders[i] = http.CanonicalHeaderKey(c.AllowedHeaders[i])
+	}
+
+	for i := range c.ExposedHeaders {
+		c.ExposedHeaders[i] = http.CanonicalHeaderKey(c.ExposedHeaders[i])
+	}
+
+	if c.MaxAge == 0 {
+		c.MaxAge = time.Minute * 10
+	}
+
+	if c.MaxAge < 0 {
+		return errors.E(op, errors.Str("max_age should not be negative"))
+	}
+
+	return nil
+}
+
+// allowedOrigin reports whether the cross-origin requests are allowed from the origin.
+func (c *Config) allowedOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+
+		prefix, suffix, ok := strings.Cut(o, "*")
+		if ok && len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,262 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeGRPC    string = "application/grpc"
+	contentTypeWeb     string = "application/grpc-web"
+	contentTypeWebText string = "application/grpc-web-text"
+
+	// the headers sent by the gRPC-Web and Connect clients
+	defaultAllowedHeaders string = "Content-Type, X-Grpc-Web, X-User-Agent, Grpc-Timeout, Connect-Protocol-Version, Connect-Timeout-Ms"
+	// the response headers the clients read the status from
+	defaultExposedHeaders string = "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin"
+
+	// the most significant bit of the frame flags marks the trailers frame
+	trailersFlag byte = 0x80
+)
+
+// Handler serves the gRPC-Web requests by the wrapped gRPC handler (grpc.Server). Other requests are passed
+// to the wrapped handler as is.
+type Handler struct {
+	cfg  *Config
+	next http.Handler
+}
+
+// NewHandler wraps the gRPC handler.
+func NewHandler(cfg *Config, next http.Handler) *Handler {
+	return &Handler{
+		cfg:  cfg,
+		next: next,
+	}
+}
+
+// IsGrpcWebRequest reports whether the request uses the gRPC-Web protocol.
+func IsGrpcWebRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeWeb)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+
+	if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+		h.preflight(w, origin)
+		return
+	}
+
+	// the wrapped handler might serve other browser protocols (Connect), so CORS is applied to all requests
+	if origin != "" && h.cfg.allowedOrigin(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(append([]string{defaultExposedHeaders}, h.cfg.ExposedHeaders...), ", "))
+		if h.cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !IsGrpcWebRequest(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, contentTypeWebText)
+
+	// the gRPC handler accepts only the HTTP/2 requests, application/grpc-web+proto -> application/grpc+proto
+	r.ProtoMajor, r.ProtoMinor, r.Proto = 2, 0, "HTTP/2.0"
+	if text {
+		r.Header.Set("Content-Type", contentTypeGRPC+strings.TrimPrefix(contentType, contentTypeWebText))
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		r.Body = io.NopCloser(&textReader{src: r.Body, buf: make([]byte, 4096)})
+	} else {
+		r.Header.Set("Content-Type", contentTypeGRPC+strings.TrimPrefix(contentType, contentTypeWeb))
+	}
+
+	rw := &responseWriter{
+		w:           w,
+		header:      make(http.Header),
+		contentType: contentType,
+		text:        text,
+	}
+
+	h.next.ServeHTTP(rw, r)
+	rw.finish()
+}
+
+func (h *Handler) preflight(w http.ResponseWriter, origin string) {
+	if !h.cfg.allowedOrigin(origin) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	hdr := w.Header()
+	hdr.Set("Access-Control-Allow-Origin", origin)
+	hdr.Add("Vary", "Origin")
+	hdr.Set("Access-Control-Allow-Methods", http.MethodPost)
+	hdr.Set("Access-Control-Allow-Headers", strings.Join(append([]string{defaultAllowedHeaders}, h.cfg.AllowedHeaders...), ", "))
+	hdr.Set("Access-Control-Max-Age", strconv.Itoa(int(h.cfg.MaxAge.Seconds())))
+	if h.cfg.AllowCredentials {
+		hdr.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// responseWriter sends the headers set after the first write in the body trailers frame,
+// the body is base64-encoded for the text requests.
+type responseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	contentType string
+	text        bool
+
+	wroteHeader bool
+	status      int
+	// the headers sent before the body
+	sent []string
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = code
+
+	hdr := rw.w.Header()
+	for k, v := range rw.header {
+		// the trailers are declared by the gRPC handler, but sent in the body
+		if k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+
+		hdr[k] = v
+		rw.sent = append(rw.sent, k)
+	}
+
+	if strings.HasPrefix(hdr.Get("Content-Type"), contentTypeGRPC) {
+		hdr.Set("Content-Type", rw.contentType)
+	}
+
+	rw.w.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if rw.text {
+		_, err := rw.w.Write([]byte(base64.StdEncoding.EncodeToString(b)))
+		if err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	return rw.w.Write(b)
+}
+
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish writes the trailers frame.
+func (rw *responseWriter) finish() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	// the request was rejected by the gRPC handler, the body is a plain text error
+	if rw.status != http.StatusOK {
+		return
+	}
+
+	var buf bytes.Buffer
+	for k, v := range rw.header {
+		if k == "Trailer" || slices.Contains(rw.sent, k) {
+			continue
+		}
+
+		k = strings.ToLower(strings.TrimPrefix(k, http.TrailerPrefix))
+		for _, vv := range v {
+			buf.WriteString(k)
+			buf.WriteString(": ")
+			buf.WriteString(vv)
+			buf.WriteString("\r\n")
+		}
+	}
+
+	frame := make([]byte, 5, 5+buf.Len())
+	frame[0] = trailersFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(buf.Len())) //nolint:gosec
+	frame = append(frame, buf.Bytes()...)
+
+	_, _ = rw.Write(frame)
+	rw.Flush()
+}
+
+// textReader decodes the base64-encoded request body. The body might be a concatenation of the padded chunks,
+// so it is decoded by 4-byte quantums.
+type textReader struct {
+	src io.Reader
+	buf []byte
+	// not decoded yet
+	in []byte
+	// decoded, but not read
+	out []byte
+	err error
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		if t.err != nil {
+			if t.err == io.EOF && len(t.in) > 0 { //nolint:errorlint
+				return 0, io.ErrUnexpectedEOF
+			}
+
+			return 0, t.err
+		}
+
+		var n int
+		n, t.err = t.src.Read(t.buf)
+		t.in = append(t.in, t.buf[:n]...)
+
+		full := len(t.in) / 4 * 4
+		var quantum [3]byte
+		for i := 0; i < full; i += 4 {
+			m, err := base64.StdEncoding.Decode(quantum[:], t.in[i:i+4])
+			if err != nil {
+				return 0, err
+			}
+			t.out = append(t.out, quantum[:m]...)
+		}
+		t.in = append(t.in[:0], t.in[full:]...)
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+
+	return n, nil
+}
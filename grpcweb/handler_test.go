@@ -0,0 +1,171 @@
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// trailerServer sends a custom trailer on every Check call.
+type trailerServer struct {
+	*health.Server
+}
+
+func (t *trailerServer) Check(ctx context.Context, in *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("x-trailer", "value"))
+	return t.Server.Check(ctx, in)
+}
+
+func newTestHandler(t *testing.T, cfg *Config) *Handler {
+	t.Helper()
+	require.NoError(t, cfg.InitDefaults())
+
+	srv := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(srv, &trailerServer{Server: health.NewServer()})
+	t.Cleanup(srv.Stop)
+
+	return NewHandler(cfg, srv)
+}
+
+func frame(flags byte, data []byte) []byte {
+	out := make([]byte, 5, 5+len(data))
+	out[0] = flags
+	binary.BigEndian.PutUint32(out[1:], uint32(len(data))) //nolint:gosec
+	return append(out, data...)
+}
+
+// readFrames splits the response body into the message and the trailers.
+func readFrames(t *testing.T, body []byte) ([]byte, string) {
+	t.Helper()
+	var msg []byte
+	var trailers string
+	for len(body) > 0 {
+		require.GreaterOrEqual(t, len(body), 5)
+		n := binary.BigEndian.Uint32(body[1:5])
+		data := body[5 : 5+n]
+		if body[0]&trailersFlag != 0 {
+			trailers = string(data)
+		} else {
+			msg = data
+		}
+		body = body[5+n:]
+	}
+	return msg, trailers
+}
+
+func TestConfigInitDefaults(t *testing.T) {
+	cfg := &Config{AllowedOrigins: []string{"https://*.example.com"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, "10m0s", cfg.MaxAge.String())
+
+	assert.True(t, cfg.allowedOrigin("https://app.example.com"))
+	assert.False(t, cfg.allowedOrigin("https://example.org"))
+	assert.False(t, (&Config{}).allowedOrigin("https://example.org"))
+	assert.True(t, (&Config{AllowedOrigins: []string{"*"}}).allowedOrigin("https://example.org"))
+
+	assert.Error(t, (&Config{AllowedOrigins: []string{"*.*.example.com"}}).InitDefaults())
+	assert.Error(t, (&Config{AllowedOrigins: []string{"*"}, AllowCredentials: true}).InitDefaults())
+}
+
+func TestGrpcWebBinary(t *testing.T) {
+	h := newTestHandler(t, &Config{AllowedOrigins: []string{"https://app.example.com"}})
+
+	req, err := proto.Marshal(&grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", bytes.NewReader(frame(0, req)))
+	r.Header.Set("Content-Type", "application/grpc-web+proto")
+	r.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/grpc-web+proto", w.Header().Get("Content-Type"))
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "Grpc-Status")
+	assert.Empty(t, w.Header().Get("Grpc-Status"))
+
+	msg, trailers := readFrames(t, w.Body.Bytes())
+	resp := &grpc_health_v1.HealthCheckResponse{}
+	require.NoError(t, proto.Unmarshal(msg, resp))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+	assert.Contains(t, trailers, "grpc-status: 0\r\n")
+	assert.Contains(t, trailers, "x-trailer: value\r\n")
+}
+
+func TestGrpcWebText(t *testing.T) {
+	h := newTestHandler(t, &Config{})
+
+	req, err := proto.Marshal(&grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	require.NoError(t, err)
+
+	// the body is split into two padded chunks
+	f := frame(0, req)
+	body := base64.StdEncoding.EncodeToString(f[:4]) + base64.StdEncoding.EncodeToString(f[4:])
+
+	r := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/grpc-web-text")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/grpc-web-text", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// every write is encoded separately
+	dec, err := io.ReadAll(&textReader{src: w.Body, buf: make([]byte, 16)})
+	require.NoError(t, err)
+
+	_, trailers := readFrames(t, dec)
+	assert.Contains(t, trailers, "grpc-status: 5\r\n")
+	assert.Contains(t, trailers, "grpc-message: unknown service\r\n")
+}
+
+func TestPreflight(t *testing.T) {
+	h := newTestHandler(t, &Config{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedHeaders:   []string{"authorization"},
+		AllowCredentials: true,
+	})
+
+	r := httptest.NewRequest(http.MethodOptions, "/grpc.health.v1.Health/Check", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	r.Header.Set("Origin", "https://evil.example.org")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestTextReaderMalformed(t *testing.T) {
+	_, err := io.ReadAll(&textReader{src: strings.NewReader("AAA"), buf: make([]byte, 16)})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = io.ReadAll(&textReader{src: strings.NewReader("!!!!"), buf: make([]byte, 16)})
+	assert.Error(t, err)
+}
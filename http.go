@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	stderr "errors"
+	"net/http"
+	"time"
+
+	"github.com/jhump/protoreflect/v2/protoresolve"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/connect"
+	"github.com/roadrunner-server/grpc/v6/grpcweb"
+	"github.com/roadrunner-server/grpc/v6/transcoding"
+	"github.com/roadrunner-server/tcplisten"
+)
+
+const readHeaderTimeout = time.Minute
+
+// httpServer serves the gRPC server through net/http (grpc.Server.ServeHTTP). It is used instead of the native
+// gRPC transport when the browser clients (gRPC-Web, Connect) should be served on the same listener.
+// The TLS is terminated by the listeners.
+func (p *Plugin) httpServer() *http.Server {
+	var handler http.Handler = p.server
+	if p.config.Connect != nil {
+		var registry *protoresolve.Registry
+		if p.registry != nil {
+			registry = p.registry.Registry()
+		}
+
+		handler = connect.NewHandler(
+			p.config.Connect,
+			newInvoker(p.proxyList, p.unaryInterceptors),
+			registry,
+			p.config.recvLimit(),
+			p.log.With("component", "connect"),
+			handler,
+		)
+	}
+
+	// CORS of the Connect requests is configured by the gRPC-Web options
+	if p.config.GrpcWeb != nil {
+		handler = grpcweb.NewHandler(p.config.GrpcWeb, handler)
+	}
+
+	if p.config.HTTPProbes != nil {
+		handler = p.probesHandler(handler)
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	return &http.Server{
+		Handler:           handler,
+		Protocols:         protocols,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       p.config.MaxConnectionIdle,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: int(p.config.MaxConcurrentStreams),
+			SendPingTimeout:      p.config.PingTime,
+			PingTimeout:          p.config.Timeout,
+		},
+	}
+}
+
+// serveTranscoding starts the HTTP/JSON transcoding server, the serving errors are sent to errCh.
+func (p *Plugin) serveTranscoding(errCh chan error) error {
+	const op = errors.Op("grpc_plugin_serve_transcoding")
+
+	if p.registry == nil {
+		return errors.E(op, errors.Str("http_transcoding requires the protoreg plugin providing the proto descriptors"))
+	}
+
+	services := make([]string, 0, len(p.proxyList))
+	for _, px := range p.proxyList {
+		services = append(services, px.ServiceDesc().ServiceName)
+	}
+
+	handler, err := transcoding.NewHandler(
+		p.config.HTTPTranscoding,
+		p.registry.Registry(),
+		services,
+		newInvoker(p.proxyList, p.unaryInterceptors),
+		p.config.recvLimit(),
+		p.log.With("component", "transcoding"),
+	)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	l, err := tcplisten.CreateListener(p.config.HTTPTranscoding.Address)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	p.transcodingSrv = &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		p.log.Info("http transcoding server was started", "address", p.config.HTTPTranscoding.Address)
+
+		errS := p.transcodingSrv.Serve(l)
+		if errS != nil && !stderr.Is(errS, http.ErrServerClosed) {
+			p.log.Error("http transcoding server was stopped", "error", errS)
+			errCh <- errors.E(op, errS)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/grpc/v6/grpcweb"
+	"github.com/roadrunner-server/grpc/v6/limits"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// the tap handle is not called for the calls served through net/http, the stream rate is applied by the interceptors
+func TestStreamRateServedThroughHTTP(t *testing.T) {
+	cfg := &Config{Listen: "127.0.0.1:0", GrpcWeb: &grpcweb.Config{}, Limits: &limits.Config{StreamsPerSecond: 0.001, StreamsBurst: 2}}
+	require.NoError(t, cfg.InitDefaults())
+
+	p := &Plugin{
+		config: cfg,
+		mu:     &sync.RWMutex{},
+		log:    slog.New(slog.DiscardHandler),
+		tracer: sdktrace.NewTracerProvider(),
+		prop:   propagation.TraceContext{},
+
+		queueSize:       prometheus.NewGauge(prometheus.GaugeOpts{Name: "q"}),
+		requestCounter:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "c"}, []string{"grpc_method", "status_code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "d"}, []string{"grpc_method"}),
+		limitRejections: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lr"}, []string{"reason"}),
+	}
+	p.limiter = limits.NewLimiter(cfg.Limits, p.limitRejections)
+
+	var err error
+	p.server, err = p.createGRPCserver(nil)
+	require.NoError(t, err)
+	p.healthServer = NewHeathServer(p, p.log)
+	p.healthServer.RegisterServer(p.server)
+	p.httpSrv = p.httpServer()
+
+	listeners, err := p.listen()
+	require.NoError(t, err)
+	p.serve(listeners, make(chan error, 1))
+	t.Cleanup(func() { _ = p.httpSrv.Close() })
+
+	address := listeners[0].Addr().String()
+
+	// gRPC over h2c, served by grpc.Server.ServeHTTP
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	// gRPC-Web takes the second token of the burst
+	grpcWeb := func() string {
+		// an empty message frame
+		r, errR := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://"+address+"/grpc.health.v1.Health/Check", bytes.NewReader(make([]byte, 5)))
+		require.NoError(t, errR)
+		r.Header.Set("Content-Type", "application/grpc-web+proto")
+
+		resp, errR := http.DefaultClient.Do(r)
+		require.NoError(t, errR)
+		defer func() { _ = resp.Body.Close() }()
+
+		// the status is sent in the trailers frame of the body
+		body, errR := io.ReadAll(resp.Body)
+		require.NoError(t, errR)
+		return string(body)
+	}
+	assert.Contains(t, grpcWeb(), "grpc-status: 0")
+	assert.Contains(t, grpcWeb(), "grpc-status: 8")
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
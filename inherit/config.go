@@ -0,0 +1,38 @@
+// Package inherit creates the listeners from the inherited file descriptors: the systemd socket activation
+// (LISTEN_FDS), the descriptors passed by a parent process, and the listeners handed over by the previous
+// process through the handoff socket.
+package inherit
+
+import (
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Config configures the listeners handoff between the running process and its successor.
+type Config struct {
+	// Socket is the unix socket path the listeners are served on. The successor started with the same
+	// configuration takes the listeners from it, so both processes accept on the same sockets until the
+	// previous one is stopped.
+	Socket string `mapstructure:"socket"`
+	// Timeout limits the time to receive the listeners. Defaults to 5s.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_handoff_init")
+
+	if c.Socket == "" {
+		return errors.E(op, errors.Str("handoff socket path should be set"))
+	}
+
+	if c.Timeout == 0 {
+		c.Timeout = time.Second * 5
+	}
+
+	if c.Timeout < 0 {
+		return errors.E(op, errors.Str("handoff timeout should not be negative"))
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package inherit
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	systemdScheme string = "systemd://"
+	fdScheme      string = "fd://"
+	// the first descriptor passed by systemd, SD_LISTEN_FDS_START
+	listenFdsStart int = 3
+)
+
+var (
+	// files keeps the inherited descriptors open, a descriptor might be used by a few listeners
+	files   = make(map[uintptr]*os.File) //nolint:gochecknoglobals
+	filesMu sync.Mutex                   //nolint:gochecknoglobals
+)
+
+// IsInherited reports whether the listener address refers to an inherited descriptor:
+// `systemd://` (the first one), `systemd://<FileDescriptorName>` or `fd://<number>`.
+func IsInherited(address string) bool {
+	return strings.HasPrefix(address, systemdScheme) || strings.HasPrefix(address, fdScheme)
+}
+
+// Listen creates the listener from the inherited descriptor of the address.
+func Listen(address string) (net.Listener, error) {
+	const op = errors.Op("grpc_inherit_listen")
+
+	var fd uintptr
+	var err error
+	switch {
+	case strings.HasPrefix(address, systemdScheme):
+		fd, err = systemdFd(strings.TrimPrefix(address, systemdScheme))
+	case strings.HasPrefix(address, fdScheme):
+		var n uint64
+		n, err = strconv.ParseUint(strings.TrimPrefix(address, fdScheme), 10, 31)
+		if err != nil {
+			err = errors.Errorf("malformed file descriptor %s", strings.TrimPrefix(address, fdScheme))
+		}
+		fd = uintptr(n)
+	default:
+		err = errors.Errorf("not an inherited listener address: %s", address)
+	}
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	l, err := net.FileListener(file(fd, address))
+	if err != nil {
+		return nil, errors.E(op, errors.Errorf("file descriptor %d of %s is not a listening socket: %v", fd, address, err))
+	}
+
+	return l, nil
+}
+
+// systemdFd returns the descriptor passed by the systemd socket activation, the first one when the name is empty.
+func systemdFd(name string) (uintptr, error) {
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, errors.Errorf("LISTEN_PID %s does not match the process %d", pid, os.Getpid())
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return 0, errors.Str("no file descriptors were passed by systemd (LISTEN_FDS)")
+	}
+
+	if name == "" {
+		return uintptr(listenFdsStart), nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n && i < len(names); i++ {
+		if names[i] == name {
+			return uintptr(listenFdsStart + i), nil
+		}
+	}
+
+	return 0, errors.Errorf("no file descriptor named %s was passed by systemd (LISTEN_FDNAMES)", name)
+}
+
+func file(fd uintptr, name string) *os.File {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+
+	f, ok := files[fd]
+	if !ok {
+		f = os.NewFile(fd, name)
+		files[fd] = f
+	}
+
+	return f
+}
@@ -0,0 +1,72 @@
+//go:build unix
+
+package inherit
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenFd(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+
+	f, err := l.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	// Fd() would put the listener into the blocking mode
+	rc, err := f.SyscallConn()
+	require.NoError(t, err)
+	var fd uintptr
+	require.NoError(t, rc.Control(func(d uintptr) { fd = d }))
+
+	address := "fd://" + strconv.Itoa(int(fd))
+	assert.True(t, IsInherited(address))
+	assert.False(t, IsInherited("tcp://127.0.0.1:9001"))
+
+	inherited, err := Listen(address)
+	require.NoError(t, err)
+	defer func() { _ = inherited.Close() }()
+	assert.Equal(t, l.Addr().String(), inherited.Addr().String())
+
+	// the same descriptor could be used again
+	again, err := Listen(address)
+	require.NoError(t, err)
+	require.NoError(t, again.Close())
+
+	_, err = Listen("fd://abc")
+	assert.ErrorContains(t, err, "malformed file descriptor")
+}
+
+func TestSystemdFd(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "grpc:admin")
+
+	fd, err := systemdFd("")
+	require.NoError(t, err)
+	assert.Equal(t, uintptr(3), fd)
+
+	fd, err = systemdFd("admin")
+	require.NoError(t, err)
+	assert.Equal(t, uintptr(4), fd)
+
+	_, err = systemdFd("metrics")
+	assert.ErrorContains(t, err, "no file descriptor named metrics")
+
+	t.Setenv("LISTEN_PID", "1")
+	_, err = systemdFd("")
+	assert.ErrorContains(t, err, "does not match")
+
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	_, err = systemdFd("")
+	assert.ErrorContains(t, err, "LISTEN_FDS")
+}
@@ -0,0 +1,27 @@
+//go:build !unix
+
+package inherit
+
+import (
+	"log/slog"
+	"net"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Receive is not supported, the descriptors could not be passed over the unix sockets on this platform.
+func Receive(*Config) (map[string]net.Listener, error) {
+	return nil, errors.E(errors.Op("grpc_handoff_receive"), errors.Str("listeners handoff is not supported on this platform"))
+}
+
+// Server is not supported on this platform.
+type Server struct{}
+
+// Serve is not supported, the descriptors could not be passed over the unix sockets on this platform.
+func Serve(*Config, []string, []net.Listener, *slog.Logger) (*Server, error) {
+	return nil, errors.E(errors.Op("grpc_handoff_serve"), errors.Str("listeners handoff is not supported on this platform"))
+}
+
+func (s *Server) Close() error {
+	return nil
+}
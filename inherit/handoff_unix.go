@@ -0,0 +1,256 @@
+//go:build unix
+
+package inherit
+
+import (
+	"encoding/json"
+	stderr "errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// the most listeners handed over at once
+const maxListeners = 64
+
+// message describes the handed over descriptors, in the same order.
+type message struct {
+	Addresses []string `json:"addresses"`
+}
+
+// Receive takes the listeners from the previous process serving the handoff socket, by their configured addresses.
+// Nothing is returned when no process serves the socket.
+func Receive(cfg *Config) (map[string]net.Listener, error) {
+	const op = errors.Op("grpc_handoff_receive")
+
+	conn, err := net.DialTimeout("unix", cfg.Socket, cfg.Timeout)
+	if err != nil {
+		if stderr.Is(err, os.ErrNotExist) || stderr.Is(err, syscall.ECONNREFUSED) {
+			return nil, nil
+		}
+
+		return nil, errors.E(op, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, errors.E(op, errors.Str("handoff socket is not a unix socket"))
+	}
+	_ = uc.SetDeadline(time.Now().Add(cfg.Timeout))
+
+	buf := make([]byte, 1<<16)
+	oob := make([]byte, syscall.CmsgSpace(maxListeners*4))
+	n, oobn, flags, _, err := uc.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	fds, err := parseRights(oob[:oobn])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	closeFds := func() {
+		for _, fd := range fds {
+			_ = syscall.Close(fd)
+		}
+	}
+
+	if flags&syscall.MSG_CTRUNC != 0 {
+		closeFds()
+		return nil, errors.E(op, errors.Str("too many handed over listeners"))
+	}
+
+	rest, err := io.ReadAll(uc)
+	if err != nil {
+		closeFds()
+		return nil, errors.E(op, err)
+	}
+
+	msg := &message{}
+	err = json.Unmarshal(append(buf[:n], rest...), msg)
+	if err != nil || len(msg.Addresses) != len(fds) {
+		closeFds()
+		return nil, errors.E(op, errors.Errorf("malformed handoff message, %d descriptors received", len(fds)))
+	}
+
+	listeners := make(map[string]net.Listener, len(fds))
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), msg.Addresses[i])
+		l, errL := net.FileListener(f)
+		_ = f.Close()
+		if errL != nil {
+			for _, ln := range listeners {
+				_ = ln.Close()
+			}
+			for _, fd := range fds[i+1:] {
+				_ = syscall.Close(fd)
+			}
+			return nil, errors.E(op, errL)
+		}
+
+		listeners[msg.Addresses[i]] = l
+	}
+
+	return listeners, nil
+}
+
+func parseRights(oob []byte) ([]int, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+
+	var fds []int
+	for i := range msgs {
+		rights, errR := syscall.ParseUnixRights(&msgs[i])
+		if errR != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+
+	return fds, nil
+}
+
+// Server hands the listeners over to the successors connected to the handoff socket.
+type Server struct {
+	listener *net.UnixListener
+	info     os.FileInfo
+	log      *slog.Logger
+
+	data  []byte
+	files []*os.File
+	wg    sync.WaitGroup
+}
+
+// Serve serves the listeners by their configured addresses on the handoff socket, replacing the socket of the
+// previous process.
+func Serve(cfg *Config, addresses []string, listeners []net.Listener, log *slog.Logger) (*Server, error) {
+	const op = errors.Op("grpc_handoff_serve")
+
+	if len(listeners) > maxListeners {
+		return nil, errors.E(op, errors.Errorf("at most %d listeners could be handed over", maxListeners))
+	}
+
+	s := &Server{log: log}
+	for i, l := range listeners {
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			s.closeFiles()
+			return nil, errors.E(op, errors.Errorf("listener %s could not be handed over", addresses[i]))
+		}
+
+		f, err := fl.File()
+		if err != nil {
+			s.closeFiles()
+			return nil, errors.E(op, err)
+		}
+		s.files = append(s.files, f)
+
+		// the socket file is used by the successor after this process is stopped
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	var err error
+	s.data, err = json.Marshal(&message{Addresses: addresses})
+	if err != nil {
+		s.closeFiles()
+		return nil, errors.E(op, err)
+	}
+
+	// the socket of the previous process is replaced, it keeps serving its already accepted connections
+	err = os.Remove(cfg.Socket)
+	if err != nil && !stderr.Is(err, os.ErrNotExist) {
+		s.closeFiles()
+		return nil, errors.E(op, err)
+	}
+
+	s.listener, err = net.ListenUnix("unix", &net.UnixAddr{Name: cfg.Socket, Net: "unix"})
+	if err != nil {
+		s.closeFiles()
+		return nil, errors.E(op, err)
+	}
+	// removed only when it is still the socket of this process
+	s.listener.SetUnlinkOnClose(false)
+
+	err = os.Chmod(cfg.Socket, 0o600)
+	if err != nil {
+		_ = s.listener.Close()
+		s.closeFiles()
+		return nil, errors.E(op, err)
+	}
+
+	s.info, err = os.Stat(cfg.Socket)
+	if err != nil {
+		_ = s.listener.Close()
+		s.closeFiles()
+		return nil, errors.E(op, err)
+	}
+
+	s.wg.Go(s.serve)
+
+	return s, nil
+}
+
+func (s *Server) serve() {
+	// Fd() is not used, it puts the descriptor shared with the served listener into the blocking mode
+	fds := make([]int, 0, len(s.files))
+	for _, f := range s.files {
+		rc, err := f.SyscallConn()
+		if err != nil {
+			s.log.Error("listener descriptor is not available", "error", err)
+			return
+		}
+
+		_ = rc.Control(func(fd uintptr) {
+			fds = append(fds, int(fd))
+		})
+	}
+
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			return
+		}
+
+		_, _, err = conn.WriteMsgUnix(s.data, syscall.UnixRights(fds...), nil)
+		_ = conn.Close()
+		if err != nil {
+			s.log.Error("listeners were not handed over", "error", err)
+			continue
+		}
+
+		s.log.Info("listeners were handed over to the successor", "listeners", len(fds))
+	}
+}
+
+// Close stops serving the listeners, the socket is removed when it was not replaced by the successor.
+func (s *Server) Close() error {
+	path := s.listener.Addr().String()
+	err := s.listener.Close()
+	s.wg.Wait()
+	s.closeFiles()
+
+	if info, errS := os.Stat(path); errS == nil && os.SameFile(info, s.info) {
+		_ = os.Remove(path)
+	}
+
+	return err
+}
+
+func (s *Server) closeFiles() {
+	for _, f := range s.files {
+		_ = f.Close()
+	}
+	s.files = nil
+}
@@ -0,0 +1,80 @@
+//go:build unix
+
+package inherit
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandoff(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{Socket: filepath.Join(dir, "handoff.sock")}
+	require.NoError(t, cfg.InitDefaults())
+
+	// no previous process
+	received, err := Receive(cfg)
+	require.NoError(t, err)
+	assert.Empty(t, received)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unix, err := net.Listen("unix", filepath.Join(dir, "grpc.sock"))
+	require.NoError(t, err)
+
+	addresses := []string{"127.0.0.1:0", "unix://" + filepath.Join(dir, "grpc.sock")}
+	previous, err := Serve(cfg, addresses, []net.Listener{tcp, unix}, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+
+	received, err = Receive(cfg)
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+	assert.Equal(t, tcp.Addr().String(), received["127.0.0.1:0"].Addr().String())
+
+	// the successor replaces the handoff socket
+	successor, err := Serve(cfg, addresses, []net.Listener{received[addresses[0]], received[addresses[1]]}, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+
+	// the previous process is stopped, the sockets are still served by the successor
+	require.NoError(t, previous.Close())
+	require.NoError(t, tcp.Close())
+	require.NoError(t, unix.Close())
+
+	_, err = os.Stat(cfg.Socket)
+	require.NoError(t, err)
+
+	for _, l := range received {
+		go func() {
+			conn, errA := l.Accept()
+			if errA == nil {
+				_, _ = conn.Write([]byte("ok"))
+				_ = conn.Close()
+			}
+		}()
+	}
+
+	for _, addr := range []net.Addr{received[addresses[0]].Addr(), &net.UnixAddr{Name: filepath.Join(dir, "grpc.sock"), Net: "unix"}} {
+		conn, errD := net.Dial(addr.Network(), addr.String())
+		require.NoError(t, errD, addr.String())
+		buf := make([]byte, 2)
+		_, errD = conn.Read(buf)
+		require.NoError(t, errD)
+		assert.Equal(t, "ok", string(buf))
+		_ = conn.Close()
+	}
+
+	// the own socket is removed on close
+	require.NoError(t, successor.Close())
+	_, err = os.Stat(cfg.Socket)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	for _, l := range received {
+		_ = l.Close()
+	}
+}
@@ -0,0 +1,86 @@
+// Package gateway holds the helpers shared by the HTTP gateways (the HTTP/JSON transcoding and the Connect protocol)
+// to turn an HTTP request into a gRPC call.
+package gateway
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Addr is the client address of the HTTP request.
+type Addr string
+
+func (a Addr) Network() string { return "tcp" }
+func (a Addr) String() string  { return string(a) }
+
+// Metadata converts the request headers to the gRPC metadata. Every header is mapped to the metadata key by key,
+// the header is skipped when key returns an empty string. The hop-by-hop headers, Cookie, Host and the keys reserved
+// by gRPC (`grpc-` prefixed) are never forwarded. The binary (`-bin` suffixed) values are base64-decoded.
+func Metadata(header http.Header, key func(header string) string) (metadata.MD, error) {
+	md := make(metadata.MD, len(header))
+	for k, v := range header {
+		switch k {
+		case "Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Upgrade", "Te", "Trailer",
+			"Content-Length", "Cookie", "Host":
+			continue
+		}
+
+		mk := strings.ToLower(key(k))
+		if mk == "" || strings.HasPrefix(mk, "grpc-") || strings.HasPrefix(mk, ":") {
+			continue
+		}
+
+		if !strings.HasSuffix(mk, "-bin") {
+			md[mk] = append(md[mk], v...)
+			continue
+		}
+
+		// the binary values are base64-encoded, with or without the padding
+		for _, vv := range v {
+			b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(vv, "="))
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "malformed binary header %s: %v", k, err)
+			}
+			md[mk] = append(md[mk], string(b))
+		}
+	}
+
+	return md, nil
+}
+
+// HTTPStatus maps the gRPC code to the HTTP status, as described in google/rpc/code.proto.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unknown, codes.Internal, codes.DataLoss:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetadata(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer token")
+	header.Set("X-Data-Bin", base64.StdEncoding.EncodeToString([]byte("\x01\x02")))
+	header.Set("Connection", "keep-alive")
+	header.Set("Cookie", "session=1")
+	header.Set("Host", "example.com")
+	header.Set("Grpc-Timeout", "1S")
+	header.Set("Grpc-Status", "0")
+	header.Set("X-Skipped", "1")
+
+	md, err := Metadata(header, func(k string) string {
+		if k == "X-Skipped" {
+			return ""
+		}
+		return k
+	})
+	require.NoError(t, err)
+	assert.Len(t, md, 2)
+	assert.Equal(t, []string{"Bearer token"}, md.Get("authorization"))
+	assert.Equal(t, []string{"\x01\x02"}, md.Get("x-data-bin"))
+
+	header = http.Header{}
+	header.Set("X-Data-Bin", "!")
+	_, err = Metadata(header, func(k string) string { return k })
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusOK, HTTPStatus(codes.OK))
+	assert.Equal(t, 499, HTTPStatus(codes.Canceled))
+	assert.Equal(t, http.StatusBadRequest, HTTPStatus(codes.FailedPrecondition))
+	assert.Equal(t, http.StatusTooManyRequests, HTTPStatus(codes.ResourceExhausted))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(codes.Code(42)))
+}
@@ -0,0 +1,20 @@
+// Package methodname validates the fully-qualified method names of the per-method options.
+package methodname
+
+import (
+	"strings"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Normalize strips the leading slash of the method name and checks it is in the package.Service/Method form.
+func Normalize(name string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+
+	service, method, ok := strings.Cut(name, "/")
+	if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return name, errors.Errorf("malformed method name, should be in the package.Service/Method form, provided: %s", name)
+	}
+
+	return name, nil
+}
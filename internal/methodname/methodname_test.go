@@ -0,0 +1,23 @@
+package methodname
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	name, err := Normalize("/app.Service/Method")
+	require.NoError(t, err)
+	assert.Equal(t, "app.Service/Method", name)
+
+	name, err = Normalize("app.Service/Method")
+	require.NoError(t, err)
+	assert.Equal(t, "app.Service/Method", name)
+
+	for _, name := range []string{"", "Method", "app.Service/", "/Method", "app.Service/Method/Extra"} {
+		_, err = Normalize(name)
+		assert.ErrorContains(t, err, "malformed method name", name)
+	}
+}
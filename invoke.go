@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/grpc/v6/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// invoker calls the proxied methods in-process, it implements api.Invoker.
+type invoker struct {
+	methods     map[string]*invokerMethod
+	interceptor grpc.UnaryServerInterceptor
+}
+
+type invokerMethod struct {
+	srv  any
+	desc grpc.MethodDesc
+}
+
+func newInvoker(proxies []*proxy.Proxy, interceptors []grpc.UnaryServerInterceptor) *invoker {
+	inv := &invoker{
+		methods:     make(map[string]*invokerMethod),
+		interceptor: chainUnary(interceptors),
+	}
+
+	for _, px := range proxies {
+		sd := px.ServiceDesc()
+		for _, m := range sd.Methods {
+			inv.methods["/"+sd.ServiceName+"/"+m.MethodName] = &invokerMethod{srv: px, desc: m}
+		}
+	}
+
+	return inv
+}
+
+func (i *invoker) Invoke(ctx context.Context, method string, in []byte) ([]byte, metadata.MD, metadata.MD, error) {
+	m, ok := i.methods[method]
+	if !ok {
+		return nil, nil, nil, status.Errorf(codes.Unimplemented, "unknown method %s", strings.TrimPrefix(method, "/"))
+	}
+
+	st := &transportStream{method: method}
+	ctx = grpc.NewContextWithServerTransportStream(ctx, st)
+
+	dec := func(v any) error {
+		switch msg := v.(type) {
+		case *codec.RawMessage:
+			*msg = in
+			return nil
+		case proto.Message:
+			return proto.Unmarshal(in, msg)
+		default:
+			return status.Errorf(codes.Internal, "unexpected request type %T", v)
+		}
+	}
+
+	resp, err := m.desc.Handler(m.srv, ctx, dec, i.interceptor)
+	if err != nil {
+		return nil, st.headers(), st.trailers(), err
+	}
+
+	var out []byte
+	switch msg := resp.(type) {
+	case codec.RawMessage:
+		out = msg
+	case proto.Message:
+		out, err = proto.Marshal(msg)
+		if err != nil {
+			return nil, nil, nil, status.Error(codes.Internal, err.Error())
+		}
+	default:
+		return nil, nil, nil, status.Errorf(codes.Internal, "unexpected response type %T", resp)
+	}
+
+	return out, st.headers(), st.trailers(), nil
+}
+
+// chainUnary chains the interceptors the same way grpc.ChainUnaryInterceptor does, the first one is the outermost.
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var next func(i int) grpc.UnaryHandler
+		next = func(i int) grpc.UnaryHandler {
+			if i == len(interceptors) {
+				return handler
+			}
+
+			return func(ctx context.Context, req any) (any, error) {
+				return interceptors[i](ctx, req, info, next(i+1))
+			}
+		}
+
+		return next(0)(ctx, req)
+	}
+}
+
+// transportStream collects the metadata set by the handler, it implements grpc.ServerTransportStream.
+type transportStream struct {
+	method string
+
+	mu      sync.Mutex
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (t *transportStream) Method() string {
+	return t.method
+}
+
+func (t *transportStream) SetHeader(md metadata.MD) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.header = metadata.Join(t.header, md)
+	return nil
+}
+
+func (t *transportStream) SendHeader(md metadata.MD) error {
+	return t.SetHeader(md)
+}
+
+func (t *transportStream) SetTrailer(md metadata.MD) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.trailer = metadata.Join(t.trailer, md)
+	return nil
+}
+
+func (t *transportStream) headers() metadata.MD {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.header
+}
+
+func (t *transportStream) trailers() metadata.MD {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.trailer
+}
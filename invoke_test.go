@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestInvoker(t *testing.T) {
+	var order []string
+	interceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			order = append(order, name+":"+info.FullMethod)
+			return handler(ctx, req)
+		}
+	}
+
+	desc := grpc.MethodDesc{
+		MethodName: "Echo",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := codec.RawMessage{}
+			if err := dec(&in); err != nil {
+				return nil, err
+			}
+
+			handler := func(ctx context.Context, req any) (any, error) {
+				method, _ := grpc.Method(ctx)
+				_ = grpc.SetHeader(ctx, metadata.Pairs("method", method))
+				_ = grpc.SetTrailer(ctx, metadata.Pairs("trailer", "1"))
+
+				if string(in) == "fail" {
+					return nil, status.Error(codes.InvalidArgument, "failed")
+				}
+				return in, nil
+			}
+
+			return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/app.Service/Echo"}, handler)
+		},
+	}
+
+	inv := &invoker{
+		methods:     map[string]*invokerMethod{"/app.Service/Echo": {desc: desc}},
+		interceptor: chainUnary([]grpc.UnaryServerInterceptor{interceptor("first"), interceptor("second")}),
+	}
+
+	out, header, trailer, err := inv.Invoke(context.Background(), "/app.Service/Echo", []byte("ping"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("ping"), out)
+	assert.Equal(t, []string{"/app.Service/Echo"}, header.Get("method"))
+	assert.Equal(t, []string{"1"}, trailer.Get("trailer"))
+	assert.Equal(t, []string{"first:/app.Service/Echo", "second:/app.Service/Echo"}, order)
+
+	_, header, _, err = inv.Invoke(context.Background(), "/app.Service/Echo", []byte("fail"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, []string{"/app.Service/Echo"}, header.Get("method"))
+
+	_, _, _, err = inv.Invoke(context.Background(), "/app.Service/Unknown", nil)
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
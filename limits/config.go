@@ -0,0 +1,35 @@
+// Package limits rejects the excessive connections and streams early: the connections over the global and per-IP
+// caps are closed before the handshake, the streams over the per-IP rate are rejected by the tap handle before
+// the request is read. The calls served through net/http (gRPC-Web, Connect) and the HTTP/JSON transcoding calls
+// bypass the tap handle, the rate is applied to them by the interceptors.
+package limits
+
+import (
+	"github.com/roadrunner-server/errors"
+)
+
+// Config configures the connection and stream limits.
+type Config struct {
+	// MaxConnections limits the number of the open connections, unlimited when zero.
+	MaxConnections int `mapstructure:"max_connections"`
+	// MaxConnectionsPerIP limits the number of the open connections from a single client address, unlimited when zero.
+	MaxConnectionsPerIP int `mapstructure:"max_connections_per_ip"`
+	// StreamsPerSecond limits the rate of the new streams (calls) from a single client address, unlimited when zero.
+	StreamsPerSecond float64 `mapstructure:"streams_per_second"`
+	// StreamsBurst is the number of the streams allowed at once over the rate. Defaults to StreamsPerSecond.
+	StreamsBurst int `mapstructure:"streams_burst"`
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_limits_init")
+
+	if c.MaxConnections < 0 || c.MaxConnectionsPerIP < 0 || c.StreamsPerSecond < 0 || c.StreamsBurst < 0 {
+		return errors.E(op, errors.Str("limits should not be negative"))
+	}
+
+	if c.StreamsPerSecond > 0 && c.StreamsBurst == 0 {
+		c.StreamsBurst = max(1, int(c.StreamsPerSecond))
+	}
+
+	return nil
+}
@@ -0,0 +1,346 @@
+package limits
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/tap"
+)
+
+// the rejection reasons, reported in the rejections metric
+const (
+	reasonMaxConnections      string = "max_connections"
+	reasonMaxConnectionsPerIP string = "max_connections_per_ip"
+	reasonStreamRate          string = "stream_rate"
+)
+
+// the idle clients are forgotten after this interval
+const sweepInterval = time.Minute
+
+// Limiter tracks the open connections and the stream rates by the client address.
+type Limiter struct {
+	cfg *Config
+	// rejections counts the rejected connections and streams by the reason
+	rejections *prometheus.CounterVec
+
+	mu          sync.Mutex
+	connections int
+	clients     map[netip.Addr]*client
+	lastSweep   time.Time
+}
+
+// client is the state of a single client address.
+type client struct {
+	connections int
+	// the token bucket of the new streams
+	tokens  float64
+	updated time.Time
+}
+
+// NewLimiter creates the limiter, rejections might be nil.
+func NewLimiter(cfg *Config, rejections *prometheus.CounterVec) *Limiter {
+	return &Limiter{
+		cfg:        cfg,
+		rejections: rejections,
+		clients:    make(map[netip.Addr]*client),
+		lastSweep:  time.Now(),
+	}
+}
+
+// Listener limits the connections accepted by the listener, the limiter is shared between the listeners.
+func (l *Limiter) Listener(ln net.Listener) net.Listener {
+	return &listener{Listener: ln, limiter: l}
+}
+
+// tappedKey marks the context of the streams already admitted by the tap handle.
+type tappedKey struct{}
+
+// TapHandle rejects the new streams over the per-IP rate with RESOURCE_EXHAUSTED, before the request is read.
+func (l *Limiter) TapHandle(ctx context.Context, _ *tap.Info) (context.Context, error) {
+	err := l.allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return context.WithValue(ctx, tappedKey{}, true), nil
+}
+
+// UnaryServerInterceptor applies the per-IP rate to the calls the tap handle is not called for: the calls served
+// through net/http (gRPC-Web, Connect) and the in-process calls of the HTTP/JSON transcoding.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if ctx.Value(tappedKey{}) == nil {
+			err := l.allow(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor applies the per-IP rate to the streams served through net/http.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if ss.Context().Value(tappedKey{}) == nil {
+			err := l.allow(ss.Context())
+			if err != nil {
+				return err
+			}
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// allow takes a token of the stream rate of the client address.
+func (l *Limiter) allow(ctx context.Context) error {
+	if l.cfg.StreamsPerSecond == 0 {
+		return nil
+	}
+
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	addr, ok := clientAddr(pr.Addr)
+	if !ok {
+		return nil
+	}
+
+	if !l.allowStream(addr, time.Now()) {
+		l.reject(reasonStreamRate)
+		return status.Error(codes.ResourceExhausted, "too many requests from the client address")
+	}
+
+	return nil
+}
+
+// acquire counts the new connection against the global limit.
+func (l *Limiter) acquire() bool {
+	if l.cfg.MaxConnections == 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.connections >= l.cfg.MaxConnections {
+		return false
+	}
+
+	l.connections++
+	return true
+}
+
+// release releases the global slot of the closed connection.
+func (l *Limiter) release() {
+	if l.cfg.MaxConnections == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	l.connections--
+	l.mu.Unlock()
+}
+
+// acquireClient counts the connection against the per-IP limit.
+func (l *Limiter) acquireClient(addr netip.Addr) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.client(addr, time.Now())
+	if c.connections >= l.cfg.MaxConnectionsPerIP {
+		return false
+	}
+
+	c.connections++
+	return true
+}
+
+func (l *Limiter) releaseClient(addr netip.Addr) {
+	l.mu.Lock()
+	if c, ok := l.clients[addr]; ok {
+		c.connections--
+	}
+	l.mu.Unlock()
+}
+
+// allowStream takes a token from the bucket of the client.
+func (l *Limiter) allowStream(addr netip.Addr, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.client(addr, now)
+	c.tokens = min(float64(l.cfg.StreamsBurst), c.tokens+now.Sub(c.updated).Seconds()*l.cfg.StreamsPerSecond)
+	c.updated = now
+
+	if c.tokens < 1 {
+		return false
+	}
+
+	c.tokens--
+	return true
+}
+
+// client returns the state of the client address, the idle clients are swept periodically. Should be called
+// under the lock.
+func (l *Limiter) client(addr netip.Addr, now time.Time) *client {
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.lastSweep = now
+		for a, c := range l.clients {
+			// no connections and the bucket is already refilled
+			if c.connections == 0 && now.Sub(c.updated) > sweepInterval {
+				delete(l.clients, a)
+			}
+		}
+	}
+
+	c, ok := l.clients[addr]
+	if !ok {
+		c = &client{tokens: float64(l.cfg.StreamsBurst), updated: now}
+		l.clients[addr] = c
+	}
+
+	return c
+}
+
+func (l *Limiter) reject(reason string) {
+	if l.rejections != nil {
+		l.rejections.WithLabelValues(reason).Inc()
+	}
+}
+
+// clientAddr returns the IP of the TCP address, the unix socket clients are not limited per address.
+func clientAddr(addr net.Addr) (netip.Addr, bool) {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.AddrPort().Addr().Unmap(), true
+	}
+
+	// the requests served through net/http carry the remote address as a string
+	if addr == nil || addr.Network() != "tcp" {
+		return netip.Addr{}, false
+	}
+
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return ap.Addr().Unmap(), true
+}
+
+// listener closes the connections over the global limit right away, the per-IP limit is checked on the first
+// read, so the remote address (which might come from the PROXY header) is not resolved in the accept loop.
+type listener struct {
+	net.Listener
+	limiter *Limiter
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if !l.limiter.acquire() {
+			l.limiter.reject(reasonMaxConnections)
+			_ = c.Close()
+			continue
+		}
+
+		return &conn{Conn: c, limiter: l.limiter}, nil
+	}
+}
+
+// conn releases the limits on close.
+type conn struct {
+	net.Conn
+	limiter *Limiter
+
+	admitOnce sync.Once
+	admitErr  error
+	// client is the address counted against the per-IP limit
+	client  netip.Addr
+	counted bool
+
+	closeOnce sync.Once
+	mu        sync.Mutex
+	closed    bool
+}
+
+// admit checks the per-IP limit.
+func (c *conn) admit() error {
+	c.admitOnce.Do(func() {
+		if c.limiter.cfg.MaxConnectionsPerIP == 0 {
+			return
+		}
+
+		addr, ok := clientAddr(c.Conn.RemoteAddr())
+		if !ok {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.closed {
+			return
+		}
+
+		if !c.limiter.acquireClient(addr) {
+			c.limiter.reject(reasonMaxConnectionsPerIP)
+			c.admitErr = net.ErrClosed
+			_ = c.Conn.Close()
+			return
+		}
+
+		c.client, c.counted = addr, true
+	})
+
+	return c.admitErr
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	if err := c.admit(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Read(b)
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	if err := c.admit(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Write(b)
+}
+
+func (c *conn) Close() error {
+	err := c.Conn.Close()
+
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		counted := c.counted
+		c.mu.Unlock()
+
+		if counted {
+			c.limiter.releaseClient(c.client)
+		}
+		c.limiter.release()
+	})
+
+	return err
+}
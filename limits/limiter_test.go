@@ -0,0 +1,176 @@
+package limits
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func rejected(t *testing.T, c *prometheus.CounterVec, reason string) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	require.NoError(t, c.WithLabelValues(reason).Write(m))
+	return m.GetCounter().GetValue()
+}
+
+// dial connects to the listener and returns the accepted connection, nil when it was closed by the limits.
+func dial(t *testing.T, l net.Listener, accepted chan net.Conn) net.Conn {
+	t.Helper()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	select {
+	case c := <-accepted:
+		// the per-IP limit is checked on the first read
+		_ = c.SetReadDeadline(time.Now().Add(time.Millisecond * 10))
+		_, err = c.Read(make([]byte, 1))
+		if !assert.NotErrorIs(t, err, net.ErrClosed) {
+			return nil
+		}
+		_ = c.SetReadDeadline(time.Time{})
+		return c
+	case <-time.After(time.Millisecond * 200):
+		return nil
+	}
+}
+
+func TestListenerLimits(t *testing.T) {
+	cfg := &Config{MaxConnections: 3, MaxConnectionsPerIP: 2}
+	require.NoError(t, cfg.InitDefaults())
+
+	rejections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "rejections"}, []string{"reason"})
+	limiter := NewLimiter(cfg, rejections)
+
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l := limiter.Listener(raw)
+	defer func() { _ = l.Close() }()
+
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			c, errA := l.Accept()
+			if errA != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	first := dial(t, l, accepted)
+	require.NotNil(t, first)
+	second := dial(t, l, accepted)
+	require.NotNil(t, second)
+
+	// the third connection from the same address is closed on the first read
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+	third := <-accepted
+	_, err = third.Read(make([]byte, 1))
+	require.ErrorIs(t, err, net.ErrClosed)
+	assert.Equal(t, float64(1), rejected(t, rejections, reasonMaxConnectionsPerIP))
+	_ = third.Close()
+
+	// the closed connections release the slots
+	require.NoError(t, first.Close())
+	_ = first.Close()
+	first = dial(t, l, accepted)
+	require.NotNil(t, first)
+
+	limiter.mu.Lock()
+	assert.Equal(t, 2, limiter.connections)
+	assert.Equal(t, 2, limiter.clients[netip.MustParseAddr("127.0.0.1")].connections)
+	limiter.mu.Unlock()
+
+	// the global limit is checked on accept
+	cfg.MaxConnectionsPerIP = 0
+	require.NotNil(t, dial(t, l, accepted))
+
+	client, err = net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = client.Read(make([]byte, 1))
+	require.Error(t, err)
+	assert.Equal(t, float64(1), rejected(t, rejections, reasonMaxConnections))
+	assert.Empty(t, accepted)
+}
+
+func TestStreamRate(t *testing.T) {
+	cfg := &Config{StreamsPerSecond: 2}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, 2, cfg.StreamsBurst)
+
+	limiter := NewLimiter(cfg, nil)
+	addr := netip.MustParseAddr("192.0.2.1")
+	other := netip.MustParseAddr("192.0.2.2")
+
+	now := time.Now()
+	assert.True(t, limiter.allowStream(addr, now))
+	assert.True(t, limiter.allowStream(addr, now))
+	assert.False(t, limiter.allowStream(addr, now))
+	assert.True(t, limiter.allowStream(other, now))
+
+	// the bucket is refilled at the rate
+	assert.True(t, limiter.allowStream(addr, now.Add(time.Millisecond*500)))
+	assert.False(t, limiter.allowStream(addr, now.Add(time.Millisecond*500)))
+
+	// the idle clients are swept
+	limiter.allowStream(addr, now.Add(sweepInterval*3))
+	assert.Len(t, limiter.clients, 1)
+}
+
+func TestTapHandle(t *testing.T) {
+	cfg := &Config{StreamsPerSecond: 1}
+	require.NoError(t, cfg.InitDefaults())
+
+	rejections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "rejections"}, []string{"reason"})
+	limiter := NewLimiter(cfg, rejections)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	// the streams admitted by the tap handle are not counted by the interceptor again
+	server := grpc.NewServer(grpc.InTapHandle(limiter.TapHandle), grpc.UnaryInterceptor(limiter.UnaryServerInterceptor()))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(limiter.Listener(l)) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	client := grpc_health_v1.NewHealthClient(conn)
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, float64(1), rejected(t, rejections, reasonStreamRate))
+}
+
+func TestConfigInitDefaults(t *testing.T) {
+	cfg := &Config{MaxConnections: -1}
+	assert.Error(t, cfg.InitDefaults())
+
+	cfg = &Config{StreamsPerSecond: 0.5}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, 1, cfg.StreamsBurst)
+}
@@ -0,0 +1,248 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	stderr "errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/inherit"
+	"github.com/roadrunner-server/grpc/v6/multiplex"
+	"github.com/roadrunner-server/grpc/v6/proxyproto"
+	"github.com/roadrunner-server/tcplisten"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// listen creates the listeners of all configured addresses, the already created ones are closed on error. The
+// listeners handed over by the previous process are used first, then the inherited descriptors.
+func (p *Plugin) listen() ([]net.Listener, error) {
+	const op = errors.Op("grpc_plugin_listen")
+
+	configs := p.config.listeners()
+	listeners := make([]net.Listener, 0, len(configs))
+
+	var inherited map[string]net.Listener
+	if p.config.Handoff != nil {
+		var err error
+		inherited, err = inherit.Receive(p.config.Handoff)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	// the listeners before wrapping, handed over to the successor
+	raw := make([]net.Listener, 0, len(configs))
+	addresses := make([]string, 0, len(configs))
+
+	closeAll := func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+		for _, l := range inherited {
+			_ = l.Close()
+		}
+		for _, l := range p.probesListeners {
+			_ = l.Close()
+		}
+	}
+
+	for _, cfg := range configs {
+		tlsConfig, err := p.tlsConfig(cfg.TLS)
+		if err != nil {
+			closeAll()
+			return nil, errors.E(op, err)
+		}
+
+		var l net.Listener
+		switch il, ok := inherited[cfg.Address]; {
+		case ok:
+			delete(inherited, cfg.Address)
+			l = il
+			p.log.Info("listener was taken over from the previous process", "address", cfg.Address)
+		case inherit.IsInherited(cfg.Address):
+			l, err = inherit.Listen(cfg.Address)
+		default:
+			l, err = tcplisten.CreateListener(cfg.Address)
+		}
+		if err != nil {
+			closeAll()
+			return nil, errors.E(op, err)
+		}
+
+		raw = append(raw, l)
+		addresses = append(addresses, cfg.Address)
+
+		// the header precedes the TLS handshake
+		if p.config.ProxyProtocol != nil {
+			l = proxyproto.NewListener(l, p.config.ProxyProtocol, p.proxyProtocolConns)
+		}
+
+		// the per-IP limits use the address from the PROXY header
+		if p.limiter != nil {
+			l = p.limiter.Listener(l)
+		}
+
+		// the HTTP/1.1 connections are served by the probes server, the TLS ones could not be recognized before the handshake
+		if p.probesSrv != nil {
+			if tlsConfig == nil {
+				var probes net.Listener
+				l, probes = multiplex.Split(l, readHeaderTimeout)
+				p.probesListeners = append(p.probesListeners, probes)
+			} else {
+				p.log.Warn("http probes are not served on the TLS listener without grpc_web or connect", "address", cfg.Address)
+			}
+		}
+
+		switch {
+		case p.httpSrv != nil && tlsConfig != nil:
+			// the http server negotiates HTTP/2 on the TLS connections
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+			l = tls.NewListener(l, tlsConfig)
+		case p.httpSrv != nil, !p.config.anyTLS():
+			// served as is
+		case tlsConfig != nil:
+			l = &listener{Listener: l, creds: credentials.NewTLS(tlsConfig)}
+		default:
+			l = &listener{Listener: l, creds: insecure.NewCredentials()}
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	// the listeners of the addresses removed from the configuration are still served by the previous process
+	for _, l := range inherited {
+		_ = l.Close()
+	}
+	inherited = nil
+
+	if p.config.Handoff != nil {
+		handoff, err := inherit.Serve(p.config.Handoff, addresses, raw, p.log.With("component", "handoff"))
+		if err != nil {
+			closeAll()
+			return nil, errors.E(op, err)
+		}
+		p.handoff = handoff
+	}
+
+	return listeners, nil
+}
+
+// serve serves all listeners by the same server. The server is reported as SERVING while all listeners
+// are served, a failure of any listener is sent to errCh.
+func (p *Plugin) serve(listeners []net.Listener, errCh chan error) {
+	const op = errors.Op("grpc_plugin_serve")
+
+	addresses := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		addresses = append(addresses, l.Addr().String())
+	}
+
+	p.log.Info("grpc server was started", "addresses", addresses, "grpc_web", p.config.GrpcWeb != nil, "connect", p.config.Connect != nil)
+	p.healthServer.SetServingStatus(grpc_health_v1.HealthCheckResponse_SERVING)
+
+	var failed sync.Once
+	wg := &sync.WaitGroup{}
+	for _, l := range listeners {
+		wg.Go(func() {
+			var err error
+			if p.httpSrv != nil {
+				err = p.httpSrv.Serve(l)
+			} else {
+				err = p.server.Serve(l)
+			}
+
+			// skip errors when stopping the server
+			if err == nil || stderr.Is(err, grpc.ErrServerStopped) || stderr.Is(err, http.ErrServerClosed) {
+				return
+			}
+
+			p.log.Error("grpc listener was stopped", "address", l.Addr().String(), "error", err)
+			failed.Do(func() {
+				p.healthServer.SetServingStatus(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+				errCh <- errors.E(op, err)
+			})
+		})
+	}
+
+	for _, l := range p.probesListeners {
+		go func() {
+			err := p.probesSrv.Serve(l)
+			if err != nil && !stderr.Is(err, http.ErrServerClosed) {
+				p.log.Error("http probes listener was stopped", "address", l.Addr().String(), "error", err)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		p.healthServer.Shutdown()
+	}()
+}
+
+// listener marks the accepted connections with the credentials of the listener.
+type listener struct {
+	net.Listener
+	creds credentials.TransportCredentials
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &listenerConn{Conn: conn, creds: l.creds}, nil
+}
+
+type listenerConn struct {
+	net.Conn
+	creds credentials.TransportCredentials
+}
+
+// listenerCredentials makes the server handshake with the credentials of the listener accepted the connection,
+// so the plaintext and TLS listeners could be served by the same server. The connection security is reported by
+// the AuthInfo of the listener credentials, the server-wide protocol is TLS only when all listeners use it.
+type listenerCredentials struct {
+	protocol string
+}
+
+func newListenerCredentials(listeners []*Listener) listenerCredentials {
+	for _, l := range listeners {
+		if !l.TLS.enabled() {
+			return listenerCredentials{protocol: insecure.NewCredentials().Info().SecurityProtocol}
+		}
+	}
+
+	return listenerCredentials{protocol: "tls"}
+}
+
+func (listenerCredentials) ClientHandshake(context.Context, string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.Str("listener credentials could be used only by the server")
+}
+
+func (listenerCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, ok := rawConn.(*listenerConn)
+	if !ok {
+		return insecure.NewCredentials().ServerHandshake(rawConn)
+	}
+
+	return conn.creds.ServerHandshake(conn.Conn)
+}
+
+func (c listenerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: c.protocol}
+}
+
+func (c listenerCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (listenerCredentials) OverrideServerName(string) error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/grpc/v6/proxyproto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+// writeCert writes a self-signed certificate for the names and its key to dir, returns the file paths.
+func writeCert(t *testing.T, dir string, names ...string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: names[0]},
+		DNSNames:              names,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, names[0]+".crt")
+	keyFile := filepath.Join(dir, names[0]+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+
+	return certFile, keyFile
+}
+
+func TestListeners(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "localhost")
+	socket := filepath.Join(dir, "grpc.sock")
+
+	cfg := &Config{
+		Listen: []any{
+			"127.0.0.1:0",
+			map[string]any{"address": "tcp://127.0.0.1:0", "tls": map[string]any{"cert": certFile, "key": keyFile}},
+			map[string]any{"address": "unix://" + socket},
+		},
+	}
+	require.NoError(t, cfg.InitDefaults())
+	require.True(t, cfg.anyTLS())
+	// the plaintext listeners are served as well
+	assert.Equal(t, "insecure", newListenerCredentials(cfg.listeners()).Info().SecurityProtocol)
+	assert.Equal(t, "tls", newListenerCredentials(cfg.listeners()[1:2]).Info().SecurityProtocol)
+
+	p := &Plugin{config: cfg, log: slog.New(slog.DiscardHandler)}
+
+	opts, err := p.serverOptions()
+	require.NoError(t, err)
+	p.server = grpc.NewServer(opts...)
+	p.healthServer = NewHeathServer(p, p.log)
+	p.healthServer.RegisterServer(p.server)
+
+	listeners, err := p.listen()
+	require.NoError(t, err)
+	require.Len(t, listeners, 3)
+
+	errCh := make(chan error, 1)
+	p.serve(listeners, errCh)
+
+	caPEM, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(caPEM))
+
+	targets := []struct {
+		target string
+		creds  credentials.TransportCredentials
+		secure bool
+	}{
+		{listeners[0].Addr().String(), insecure.NewCredentials(), false},
+		{listeners[1].Addr().String(), credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost", MinVersion: tls.VersionTLS12}), true},
+		{"unix://" + socket, insecure.NewCredentials(), false},
+	}
+
+	for _, tt := range targets {
+		conn, errD := grpc.NewClient(tt.target, grpc.WithTransportCredentials(tt.creds))
+		require.NoError(t, errD)
+
+		var pr peer.Peer
+		resp, errC := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Peer(&pr))
+		require.NoError(t, errC, tt.target)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+		_, isTLS := pr.AuthInfo.(credentials.TLSInfo)
+		assert.Equal(t, tt.secure, isTLS, tt.target)
+		if tt.secure {
+			assert.Equal(t, "tls", pr.AuthInfo.AuthType(), tt.target)
+		} else {
+			assert.Equal(t, "insecure", pr.AuthInfo.AuthType(), tt.target)
+		}
+		require.NoError(t, conn.Close())
+	}
+
+	// the plaintext connections are refused by the TLS listener
+	conn, err := grpc.NewClient(listeners[1].Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	cancel()
+	assert.Error(t, err)
+	require.NoError(t, conn.Close())
+
+	// all listeners are stopped as a unit
+	p.server.GracefulStop()
+	for _, l := range listeners {
+		_, err = net.Dial(l.Addr().Network(), l.Addr().String())
+		assert.Error(t, err)
+	}
+
+	select {
+	case err = <-errCh:
+		t.Fatalf("unexpected serving error: %v", err)
+	default:
+	}
+}
+
+func TestListenersProxyProtocol(t *testing.T) {
+	cfg := &Config{
+		Listen:        "127.0.0.1:0",
+		ProxyProtocol: &proxyproto.Config{Trusted: []string{"127.0.0.1/32"}},
+	}
+	require.NoError(t, cfg.InitDefaults())
+
+	p := &Plugin{config: cfg, log: slog.New(slog.DiscardHandler)}
+
+	var got net.Addr
+	p.server = grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if pr, ok := peer.FromContext(ctx); ok {
+			got = pr.Addr
+		}
+		return handler(ctx, req)
+	}))
+	p.healthServer = NewHeathServer(p, p.log)
+	p.healthServer.RegisterServer(p.server)
+
+	listeners, err := p.listen()
+	require.NoError(t, err)
+	p.serve(listeners, make(chan error, 1))
+	defer p.server.Stop()
+
+	// the load balancer sends the header first
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, errD := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if errD != nil {
+			return nil, errD
+		}
+		_, errD = conn.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"))
+		return conn, errD
+	}
+
+	conn, err := grpc.NewClient("passthrough:///"+listeners[0].Addr().String(), grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "192.0.2.1:56324", got.String())
+}
@@ -0,0 +1,55 @@
+//go:build unix
+
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/roadrunner-server/grpc/v6/inherit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestListenersHandoff(t *testing.T) {
+	start := func(listen string, socket string) (*Plugin, string) {
+		cfg := &Config{Listen: listen, Handoff: &inherit.Config{Socket: socket}}
+		require.NoError(t, cfg.InitDefaults())
+
+		p := &Plugin{config: cfg, log: slog.New(slog.DiscardHandler)}
+		p.server = grpc.NewServer()
+		p.healthServer = NewHeathServer(p, p.log)
+		p.healthServer.RegisterServer(p.server)
+
+		listeners, err := p.listen()
+		require.NoError(t, err)
+		p.serve(listeners, make(chan error, 1))
+
+		return p, listeners[0].Addr().String()
+	}
+
+	socket := filepath.Join(t.TempDir(), "handoff.sock")
+	previous, addr := start("127.0.0.1:0", socket)
+
+	// the successor takes the listener of the same configured address
+	successor, successorAddr := start("127.0.0.1:0", socket)
+	defer successor.server.Stop()
+	defer func() { _ = successor.handoff.Close() }()
+	assert.Equal(t, addr, successorAddr)
+
+	previous.server.Stop()
+	require.NoError(t, previous.handoff.Close())
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+}
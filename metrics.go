@@ -16,7 +16,7 @@ type Informer interface {
 func (p *Plugin) MetricsCollector() []prometheus.Collector {
 	// p - implements Exporter interface (workers)
 	// other - request duration and count
-	return []prometheus.Collector{p.statsExporter, p.requestCounter, p.requestDuration, p.queueSize}
+	return []prometheus.Collector{p.statsExporter, p.requestCounter, p.requestDuration, p.queueSize, p.cacheRequests, p.proxyProtocolConns, p.certExpiry, p.limitRejections, p.sizeLimitRejections}
 }
 
 const (
@@ -77,7 +77,14 @@ func TestMetricsCollector(t *testing.T) {
 		queueSize:       prometheus.NewGauge(prometheus.GaugeOpts{Name: "q"}),
 		requestCounter:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "c"}, []string{"l"}),
 		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "d"}, []string{"l"}),
+		cacheRequests:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "cr"}, []string{"l"}),
+
+		proxyProtocolConns: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "pp"}, []string{"l"}),
+		certExpiry:         prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "ce"}, []string{"l"}),
+		limitRejections:    prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lr"}, []string{"l"}),
+
+		sizeLimitRejections: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "sr"}, []string{"l"}),
 	}
 
-	assert.Len(t, p.MetricsCollector(), 4)
+	assert.Len(t, p.MetricsCollector(), 9)
 }
@@ -0,0 +1,151 @@
+// Package multiplex splits the connections of a plaintext listener by the first bytes, so gRPC (h2c) and
+// plain HTTP/1.1 could be served on the same port.
+package multiplex
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+	"time"
+)
+
+// clientPreface starts every HTTP/2 connection, the h2c clients send it right away.
+const clientPreface string = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// Split returns the listeners of the connections starting with the HTTP/2 client preface (gRPC and other h2c
+// clients) and of all other connections (HTTP/1.x). The connections not sending enough bytes to be recognized
+// within the timeout are closed. l is closed when both listeners are closed.
+func Split(l net.Listener, timeout time.Duration) (h2c net.Listener, http1 net.Listener) {
+	s := &splitter{
+		Listener: l,
+		timeout:  timeout,
+		done:     make(chan struct{}),
+		open:     2,
+	}
+
+	s.h2c = &subListener{splitter: s, conns: make(chan net.Conn), closed: make(chan struct{})}
+	s.http1 = &subListener{splitter: s, conns: make(chan net.Conn), closed: make(chan struct{})}
+
+	go s.accept()
+
+	return s.h2c, s.http1
+}
+
+type splitter struct {
+	net.Listener
+	timeout time.Duration
+
+	h2c   *subListener
+	http1 *subListener
+
+	once sync.Once
+	done chan struct{}
+	err  error
+
+	// open is the number of the open sub-listeners
+	mu   sync.Mutex
+	open int
+}
+
+// accept dispatches the accepted connections until the listener is closed.
+func (s *splitter) accept() {
+	for {
+		conn, err := s.Listener.Accept()
+		if err != nil {
+			s.close(err)
+			return
+		}
+
+		go s.dispatch(conn)
+	}
+}
+
+// dispatch reads the first bytes of the connection and passes it to the matching listener.
+func (s *splitter) dispatch(conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(s.timeout))
+
+	r := bufio.NewReaderSize(conn, len(clientPreface))
+	preface := []byte(clientPreface)
+
+	// reads until the bytes diverge from the preface, HTTP/1.x diverges on the first byte
+	target := s.h2c
+	for n := 1; n <= len(preface); n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		if !bytes.Equal(b, preface[:n]) {
+			target = s.http1
+			break
+		}
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	select {
+	case target.conns <- &peekedConn{Conn: conn, r: r}:
+	case <-target.closed:
+		_ = conn.Close()
+	case <-s.done:
+		_ = conn.Close()
+	}
+}
+
+func (s *splitter) close(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+// subListener receives the dispatched connections.
+type subListener struct {
+	*splitter
+	conns chan net.Conn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (l *subListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// Close stops accepting, the shared listener is closed with the last sub-listener.
+func (l *subListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+
+		l.mu.Lock()
+		l.open--
+		last := l.open == 0
+		l.mu.Unlock()
+
+		if last {
+			err = l.Listener.Close()
+		}
+	})
+
+	return err
+}
+
+// peekedConn returns the peeked bytes before reading from the connection.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,80 @@
+package multiplex
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func accept(t *testing.T, l net.Listener, n int) []byte {
+	t.Helper()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	buf := make([]byte, n)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	return buf
+}
+
+func send(t *testing.T, address, data string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, err = conn.Write([]byte(data))
+	require.NoError(t, err)
+	return conn
+}
+
+func TestSplit(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := ln.Addr().String()
+
+	h2c, http1 := Split(ln, time.Second)
+	assert.Equal(t, address, h2c.Addr().String())
+
+	// the peeked bytes are read again
+	h2 := clientPreface + "frames"
+	send(t, address, h2)
+	assert.Equal(t, h2, string(accept(t, h2c, len(h2))))
+
+	get := "GET /health HTTP/1.1\r\n\r\n"
+	send(t, address, get)
+	assert.Equal(t, get, string(accept(t, http1, len(get))))
+
+	// diverges from the preface in the middle
+	pri := "PRI * HTTP/1.1\r\n\r\n"
+	send(t, address, pri)
+	assert.Equal(t, pri, string(accept(t, http1, len(pri))))
+
+	// the silent connections are closed after the timeout
+	silent := send(t, address, "PRI")
+	_ = silent.SetReadDeadline(time.Now().Add(time.Second * 5))
+	_, err = silent.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	// the shared listener is closed with the last sub-listener
+	require.NoError(t, http1.Close())
+	_, err = http1.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+
+	send(t, address, h2)
+	assert.Equal(t, h2, string(accept(t, h2c, len(h2))))
+
+	require.NoError(t, h2c.Close())
+	_, err = h2c.Accept()
+	assert.Error(t, err)
+
+	_, err = net.Dial("tcp", address)
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+package operations
+
+import (
+	"context"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ServiceName is the fully-qualified name of the google.longrunning.Operations service.
+const ServiceName string = "google.longrunning.Operations"
+
+// service implements google.longrunning.Operations on top of the store.
+type service struct {
+	longrunningpb.UnimplementedOperationsServer
+
+	store *Store
+}
+
+// Register registers the Operations service backed by the store on the server.
+func Register(server *grpc.Server, store *Store) {
+	longrunningpb.RegisterOperationsServer(server, &service{store: store})
+}
+
+func (s *service) GetOperation(_ context.Context, req *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	op, err := s.store.Get(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return op.proto(), nil
+}
+
+func (s *service) ListOperations(_ context.Context, req *longrunningpb.ListOperationsRequest) (*longrunningpb.ListOperationsResponse, error) {
+	ops, next, err := s.store.List(req.GetFilter(), int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &longrunningpb.ListOperationsResponse{
+		Operations:    make([]*longrunningpb.Operation, 0, len(ops)),
+		NextPageToken: next,
+	}
+	for _, op := range ops {
+		resp.Operations = append(resp.Operations, op.proto())
+	}
+
+	return resp, nil
+}
+
+func (s *service) DeleteOperation(_ context.Context, req *longrunningpb.DeleteOperationRequest) (*emptypb.Empty, error) {
+	err := s.store.Delete(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *service) CancelOperation(_ context.Context, req *longrunningpb.CancelOperationRequest) (*emptypb.Empty, error) {
+	err := s.store.Cancel(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *service) WaitOperation(ctx context.Context, req *longrunningpb.WaitOperationRequest) (*longrunningpb.Operation, error) {
+	op, err := s.store.Wait(ctx, req.GetName(), req.GetTimeout().AsDuration())
+	if err != nil {
+		return nil, err
+	}
+
+	return op.proto(), nil
+}
+
+// proto converts the snapshot to the google.longrunning.Operation message.
+func (op *Operation) proto() *longrunningpb.Operation {
+	out := &longrunningpb.Operation{
+		Name: op.Name,
+		Done: op.Done,
+	}
+
+	switch {
+	case op.Error != nil:
+		out.Result = &longrunningpb.Operation_Error{Error: op.Error}
+	case op.Response != nil:
+		out.Result = &longrunningpb.Operation_Response{Response: op.Response}
+	}
+
+	return out
+}
+
+// MarshalOperation encodes the operation as a google.longrunning.Operation message.
+func MarshalOperation(op *Operation) ([]byte, error) {
+	return proto.Marshal(op.proto())
+}
@@ -0,0 +1,85 @@
+package operations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestMarshalOperation(t *testing.T) {
+	for _, op := range []*Operation{
+		{Name: "operations/1"},
+		{Name: "operations/2", Done: true, Response: &anypb.Any{TypeUrl: "type.googleapis.com/app.Result", Value: []byte{1, 2}}},
+		{Name: "operations/3", Done: true, Error: status.New(codes.Internal, "fail").Proto()},
+	} {
+		data, err := MarshalOperation(op)
+		require.NoError(t, err)
+
+		got := &longrunningpb.Operation{}
+		require.NoError(t, proto.Unmarshal(data, got))
+		assert.Equal(t, op.Name, got.GetName())
+		assert.Equal(t, op.Done, got.GetDone())
+		assert.True(t, proto.Equal(op.Error, got.GetError()))
+		assert.True(t, proto.Equal(op.Response, got.GetResponse()))
+	}
+}
+
+func TestService(t *testing.T) {
+	s := newTestStore(t, 10)
+	svc := &service{store: s}
+
+	op, err := s.Start(time.Minute, func(context.Context) (*anypb.Any, error) {
+		return &anypb.Any{TypeUrl: "type.googleapis.com/app.Result", Value: []byte("ok")}, nil
+	})
+	require.NoError(t, err)
+
+	got, err := svc.WaitOperation(context.Background(), &longrunningpb.WaitOperationRequest{Name: op.Name, Timeout: durationpb.New(time.Second)})
+	require.NoError(t, err)
+	assert.True(t, got.GetDone())
+	assert.Equal(t, []byte("ok"), got.GetResponse().GetValue())
+
+	got, err = svc.GetOperation(context.Background(), &longrunningpb.GetOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+	assert.Equal(t, op.Name, got.GetName())
+
+	list, err := svc.ListOperations(context.Background(), &longrunningpb.ListOperationsRequest{Filter: "done=true"})
+	require.NoError(t, err)
+	require.Len(t, list.GetOperations(), 1)
+	assert.Equal(t, op.Name, list.GetOperations()[0].GetName())
+	assert.Empty(t, list.GetNextPageToken())
+
+	_, err = svc.ListOperations(context.Background(), &longrunningpb.ListOperationsRequest{Filter: "name=foo"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = svc.CancelOperation(context.Background(), &longrunningpb.CancelOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+
+	_, err = svc.DeleteOperation(context.Background(), &longrunningpb.DeleteOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+	_, err = svc.GetOperation(context.Background(), &longrunningpb.GetOperationRequest{Name: op.Name})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = svc.CancelOperation(context.Background(), &longrunningpb.CancelOperationRequest{Name: op.Name})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// the running operation should be canceled before it is deleted
+	release := make(chan struct{})
+	defer close(release)
+	op, err = s.Start(time.Minute, func(context.Context) (*anypb.Any, error) {
+		<-release
+		return &anypb.Any{}, nil
+	})
+	require.NoError(t, err)
+
+	_, err = svc.DeleteOperation(context.Background(), &longrunningpb.DeleteOperationRequest{Name: op.Name})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,309 @@
+// Package operations implements an in-memory google.longrunning.Operations service for the long-running worker calls.
+package operations
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const namePrefix string = "operations/"
+
+// Operation is a snapshot of a long-running operation.
+type Operation struct {
+	Name     string
+	Done     bool
+	Error    *spb.Status
+	Response *anypb.Any
+}
+
+type operation struct {
+	Operation
+
+	created  time.Time
+	finished time.Time
+	cancel   context.CancelFunc
+	// closed when the operation is done
+	done chan struct{}
+}
+
+// Store keeps the operations in memory. Finished operations are removed after the retention period.
+type Store struct {
+	mu        sync.Mutex
+	log       *slog.Logger
+	retention time.Duration
+	max       int
+	ops       map[string]*operation
+	stopped   bool
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewStore creates an operations store keeping at most maxOperations operations.
+func NewStore(retention time.Duration, maxOperations int, log *slog.Logger) *Store {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Store{
+		log:       log,
+		retention: retention,
+		max:       maxOperations,
+		ops:       make(map[string]*operation),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start runs fn in the background and returns the created operation. The operation's response is the value
+// returned by fn, its error is the status of the returned error.
+func (s *Store) Start(timeout time.Duration, fn func(ctx context.Context) (*anypb.Any, error)) (*Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return nil, status.Error(codes.Unavailable, "server is stopping")
+	}
+
+	s.evict(time.Now())
+	if len(s.ops) >= s.max {
+		return nil, status.Error(codes.ResourceExhausted, "too many operations")
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	op := &operation{
+		Operation: Operation{Name: namePrefix + newID()},
+		created:   time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	s.ops[op.Name] = op
+
+	snapshot := op.Operation
+
+	s.wg.Go(func() {
+		defer cancel()
+
+		resp, err := fn(ctx)
+
+		s.mu.Lock()
+		op.Done = true
+		op.finished = time.Now()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// canceled or timed out
+			op.Error = status.FromContextError(ctx.Err()).Proto()
+		case err != nil:
+			op.Error = status.Convert(err).Proto()
+		default:
+			op.Response = resp
+		}
+		s.mu.Unlock()
+
+		close(op.done)
+
+		if err != nil {
+			s.log.Warn("operation failed", "name", op.Name, "error", err)
+		}
+	})
+
+	return &snapshot, nil
+}
+
+// Get returns the operation snapshot.
+func (s *Store) Get(name string) (*Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.ops[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "operation %s not found", name)
+	}
+
+	snapshot := op.Operation
+	return &snapshot, nil
+}
+
+// List returns the operations ordered by the creation time, starting after the page token (an operation name).
+// The filter supports `done=true` and `done=false`.
+func (s *Store) List(filter string, pageSize int, pageToken string) ([]*Operation, string, error) {
+	var done *bool
+	yes, no := true, false
+	switch filter {
+	case "":
+	case "done=true", "done = true":
+		done = &yes
+	case "done=false", "done = false":
+		done = &no
+	default:
+		return nil, "", status.Errorf(codes.InvalidArgument, "unsupported filter: %s", filter)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict(time.Now())
+
+	all := make([]*operation, 0, len(s.ops))
+	for _, op := range s.ops {
+		if done == nil || op.Done == *done {
+			all = append(all, op)
+		}
+	}
+
+	slices.SortFunc(all, func(a, b *operation) int {
+		if c := a.created.Compare(b.created); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	start := 0
+	if pageToken != "" {
+		start = slices.IndexFunc(all, func(op *operation) bool { return op.Name == pageToken }) + 1
+		if start == 0 {
+			return nil, "", status.Error(codes.InvalidArgument, "invalid page token")
+		}
+	}
+
+	if pageSize <= 0 || pageSize > len(all)-start {
+		pageSize = len(all) - start
+	}
+
+	page := make([]*Operation, 0, pageSize)
+	for _, op := range all[start : start+pageSize] {
+		snapshot := op.Operation
+		page = append(page, &snapshot)
+	}
+
+	var next string
+	if start+pageSize < len(all) {
+		next = all[start+pageSize-1].Name
+	}
+
+	return page, next, nil
+}
+
+// Wait blocks until the operation is done, the timeout expires or ctx is done and returns the latest snapshot.
+func (s *Store) Wait(ctx context.Context, name string, timeout time.Duration) (*Operation, error) {
+	s.mu.Lock()
+	op, ok := s.ops[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "operation %s not found", name)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case <-op.done:
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := op.Operation
+	return &snapshot, nil
+}
+
+// Cancel requests the cancellation of the operation, the worker call context is canceled.
+func (s *Store) Cancel(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.ops[name]
+	if !ok {
+		return status.Errorf(codes.NotFound, "operation %s not found", name)
+	}
+
+	op.cancel()
+	return nil
+}
+
+// Delete removes the finished operation from the store. A running operation is rejected, otherwise its worker call
+// would keep running with no way to cancel or await it, it should be canceled first.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.ops[name]
+	if !ok {
+		return status.Errorf(codes.NotFound, "operation %s not found", name)
+	}
+
+	if !op.Done {
+		return status.Errorf(codes.FailedPrecondition, "operation %s is running, cancel it first", name)
+	}
+
+	delete(s.ops, name)
+	return nil
+}
+
+// Stop rejects new operations and waits for the running ones. When ctx is done, the running operations are canceled
+// and awaited for the grace period.
+func (s *Store) Stop(ctx context.Context, grace time.Duration) error {
+	s.mu.Lock()
+	s.stopped = true
+	running := 0
+	for _, op := range s.ops {
+		if !op.Done {
+			running++
+		}
+	}
+	s.mu.Unlock()
+
+	s.log.Info("waiting for running operations", "running", running)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// canceled only after the wait, otherwise the running operations would fail immediately
+	s.cancel()
+
+	select {
+	case <-done:
+	case <-time.After(grace):
+		s.log.Error("operations were not finished after the cancellation")
+	}
+
+	return err
+}
+
+// evict removes the finished operations older than the retention period.
+func (s *Store) evict(now time.Time) {
+	for name, op := range s.ops {
+		if op.Done && now.Sub(op.finished) > s.retention {
+			delete(s.ops, name)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
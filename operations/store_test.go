@@ -0,0 +1,182 @@
+package operations
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func newTestStore(t *testing.T, maxOperations int) *Store {
+	t.Helper()
+	s := NewStore(time.Hour, maxOperations, slog.New(slog.DiscardHandler))
+	t.Cleanup(func() { _ = s.Stop(context.Background(), time.Second) })
+	return s
+}
+
+func TestStoreStartAndWait(t *testing.T) {
+	s := newTestStore(t, 10)
+
+	release := make(chan struct{})
+	op, err := s.Start(time.Minute, func(context.Context) (*anypb.Any, error) {
+		<-release
+		return &anypb.Any{TypeUrl: "type.googleapis.com/app.Result", Value: []byte("ok")}, nil
+	})
+	require.NoError(t, err)
+	assert.False(t, op.Done)
+
+	got, err := s.Get(op.Name)
+	require.NoError(t, err)
+	assert.False(t, got.Done)
+
+	// the timeout expires before the operation is done
+	got, err = s.Wait(context.Background(), op.Name, time.Millisecond)
+	require.NoError(t, err)
+	assert.False(t, got.Done)
+
+	close(release)
+	got, err = s.Wait(context.Background(), op.Name, 0)
+	require.NoError(t, err)
+	assert.True(t, got.Done)
+	assert.Nil(t, got.Error)
+	assert.Equal(t, []byte("ok"), got.Response.GetValue())
+}
+
+func TestStoreFailedAndCanceled(t *testing.T) {
+	s := newTestStore(t, 10)
+
+	op, err := s.Start(time.Minute, func(context.Context) (*anypb.Any, error) {
+		return nil, status.Error(codes.FailedPrecondition, "bad state")
+	})
+	require.NoError(t, err)
+
+	got, err := s.Wait(context.Background(), op.Name, 0)
+	require.NoError(t, err)
+	assert.True(t, got.Done)
+	assert.Equal(t, int32(codes.FailedPrecondition), got.Error.GetCode())
+	assert.Equal(t, "bad state", got.Error.GetMessage())
+
+	op, err = s.Start(time.Minute, func(ctx context.Context) (*anypb.Any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	require.NoError(t, err)
+
+	// the running operation is not deleted
+	assert.Equal(t, codes.FailedPrecondition, status.Code(s.Delete(op.Name)))
+	_, err = s.Get(op.Name)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Cancel(op.Name))
+	got, err = s.Wait(context.Background(), op.Name, 0)
+	require.NoError(t, err)
+	assert.True(t, got.Done)
+	assert.Equal(t, int32(codes.Canceled), got.Error.GetCode())
+
+	require.NoError(t, s.Delete(op.Name))
+	_, err = s.Get(op.Name)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, codes.NotFound, status.Code(s.Cancel(op.Name)))
+}
+
+func TestStoreLimitAndStop(t *testing.T) {
+	s := NewStore(time.Hour, 1, slog.New(slog.DiscardHandler))
+
+	release := make(chan struct{})
+	_, err := s.Start(time.Minute, func(context.Context) (*anypb.Any, error) {
+		<-release
+		return &anypb.Any{}, nil
+	})
+	require.NoError(t, err)
+
+	_, err = s.Start(time.Minute, func(context.Context) (*anypb.Any, error) { return &anypb.Any{}, nil })
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	close(release)
+	require.NoError(t, s.Stop(context.Background(), time.Second))
+
+	_, err = s.Start(time.Minute, func(context.Context) (*anypb.Any, error) { return &anypb.Any{}, nil })
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestStoreStopGrace(t *testing.T) {
+
+	s := NewStore(time.Hour, 10, slog.New(slog.DiscardHandler))
+
+	// the operation ignores the cancellation
+	release := make(chan struct{})
+	defer close(release)
+	_, err := s.Start(time.Minute, func(context.Context) (*anypb.Any, error) {
+		<-release
+		return &anypb.Any{}, nil
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	start := time.Now()
+	assert.ErrorIs(t, s.Stop(ctx, time.Millisecond*50), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestStoreList(t *testing.T) {
+	s := newTestStore(t, 10)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	var names []string
+	for i := range 3 {
+		op, err := s.Start(time.Minute, func(context.Context) (*anypb.Any, error) {
+			if i == 2 {
+				<-release
+			}
+			return &anypb.Any{}, nil
+		})
+		require.NoError(t, err)
+		names = append(names, op.Name)
+		// distinct creation times
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, name := range names[:2] {
+		_, err := s.Wait(context.Background(), name, 0)
+		require.NoError(t, err)
+	}
+
+	page, next, err := s.List("", 2, "")
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, names[0], page[0].Name)
+	assert.Equal(t, names[1], page[1].Name)
+	assert.Equal(t, names[1], next)
+
+	page, next, err = s.List("", 2, next)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, names[2], page[0].Name)
+	assert.Empty(t, next)
+
+	page, _, err = s.List("done=false", 0, "")
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, names[2], page[0].Name)
+
+	page, _, err = s.List("done = true", 0, "")
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+
+	for _, filter := range []string{"name=foo", "done=yes", "done=true AND name=foo", "done"} {
+		_, _, err = s.List(filter, 0, "")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), filter)
+	}
+	_, _, err = s.List("", 0, "operations/unknown")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,41 @@
+// Package orca publishes the ORCA (Open Request Cost Aggregation) load reports used by the weighted client-side
+// load balancers: per call in the trailers and through the out-of-band xds.service.orca.v3.OpenRcaService.
+package orca
+
+import (
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Config configures the ORCA load reports. Both the per-call and the out-of-band reports are enabled when
+// neither is set.
+type Config struct {
+	// Trailers attaches the load report to the trailers of every unary call.
+	Trailers bool `mapstructure:"trailers"`
+	// OutOfBand registers the OpenRcaService streaming the load reports to the subscribed clients.
+	OutOfBand bool `mapstructure:"out_of_band"`
+	// MinReportInterval is the lower bound of the interval requested by the out-of-band clients, at least 30s.
+	// Default: 30s
+	MinReportInterval time.Duration `mapstructure:"min_report_interval"`
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_orca_init")
+
+	if c.MinReportInterval == 0 {
+		c.MinReportInterval = time.Second * 30
+	}
+
+	// the grpc orca service does not report more often
+	if c.MinReportInterval < time.Second*30 {
+		return errors.E(op, errors.Str("min_report_interval should be at least 30s"))
+	}
+
+	if !c.Trailers && !c.OutOfBand {
+		c.Trailers = true
+		c.OutOfBand = true
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package orca
+
+import (
+	"context"
+	"maps"
+	"sync/atomic"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/mem"
+	"google.golang.org/grpc"
+	grpcorca "google.golang.org/grpc/orca"
+)
+
+// TrailerKey is the trailer the per-call load report is sent in, the value is the binary OrcaLoadReport.
+const TrailerKey string = "endpoint-load-metrics-bin"
+
+// the report is shared by the calls and the streams finished within the interval
+const sampleInterval = time.Second
+
+// Load is the state of the workers pool the report is built from.
+type Load struct {
+	// Workers is the number of the workers in the pool
+	Workers int
+	// Busy is the number of the workers executing a call
+	Busy int
+	// Queued is the number of the calls waiting for a free worker
+	Queued int
+}
+
+// Reporter samples the host and the workers pool load and provides it to the per-call and the out-of-band
+// reports of the grpc orca package.
+type Reporter struct {
+	cfg  *Config
+	load func() Load
+
+	// calls is the number of the unary calls, the rate is computed between the samples
+	calls atomic.Uint64
+
+	// sampling is set while the sample is refreshed in the background
+	sampling atomic.Bool
+	last     atomic.Pointer[sample]
+}
+
+// sample is the load at the moment it was taken, never modified once stored.
+type sample struct {
+	metrics *grpcorca.ServerMetrics
+	taken   time.Time
+	calls   uint64
+}
+
+// NewReporter returns a reporter taking the workers pool load from the load function.
+func NewReporter(cfg *Config, load func() Load) *Reporter {
+	return &Reporter{
+		cfg:  cfg,
+		load: load,
+	}
+}
+
+// ServerMetrics returns a copy of the latest sample. The sample older than the sample interval is refreshed in
+// the background, so the calls never wait for the host or the workers pool, only the very first call samples in place.
+func (r *Reporter) ServerMetrics() *grpcorca.ServerMetrics {
+	s := r.last.Load()
+	switch {
+	case s == nil:
+		s = r.sample()
+	case time.Since(s.taken) >= sampleInterval && r.sampling.CompareAndSwap(false, true):
+		go func() {
+			defer r.sampling.Store(false)
+			r.sample()
+		}()
+	}
+
+	// the callers may modify the returned metrics
+	m := *s.metrics
+	m.Utilization = maps.Clone(s.metrics.Utilization)
+	m.RequestCost = maps.Clone(s.metrics.RequestCost)
+	m.NamedMetrics = maps.Clone(s.metrics.NamedMetrics)
+
+	return &m
+}
+
+// sample takes and stores a new sample, the calls rate is computed since the previous one.
+func (r *Reporter) sample() *sample {
+	now := time.Now()
+	calls := r.calls.Load()
+
+	metrics := &grpcorca.ServerMetrics{
+		CPUUtilization: -1,
+		MemUtilization: -1,
+		QPS:            -1,
+		EPS:            -1,
+		Utilization:    make(map[string]float64, 1),
+		RequestCost:    make(map[string]float64),
+		NamedMetrics:   make(map[string]float64, 3),
+	}
+
+	if prev := r.last.Load(); prev != nil {
+		metrics.QPS = float64(calls-prev.calls) / now.Sub(prev.taken).Seconds()
+	}
+
+	// the percent since the previous sample
+	if pct, err := cpu.Percent(0, false); err == nil && len(pct) > 0 {
+		metrics.CPUUtilization = pct[0] / 100
+	}
+
+	if vm, err := mem.VirtualMemory(); err == nil {
+		metrics.MemUtilization = vm.UsedPercent / 100
+	}
+
+	load := r.load()
+	if load.Workers > 0 {
+		metrics.AppUtilization = float64(load.Busy) / float64(load.Workers)
+	}
+
+	metrics.Utilization["workers"] = metrics.AppUtilization
+	metrics.NamedMetrics["workers_total"] = float64(load.Workers)
+	metrics.NamedMetrics["workers_busy"] = float64(load.Busy)
+	metrics.NamedMetrics["queue_depth"] = float64(load.Queued)
+
+	s := &sample{
+		metrics: metrics,
+		taken:   now,
+		calls:   calls,
+	}
+	r.last.Store(s)
+
+	return s
+}
+
+// ServerOption returns the server option sending the per-call recorder of the calls in the trailers, the recorder
+// is filled by the UnaryServerInterceptor.
+func (r *Reporter) ServerOption() grpc.ServerOption {
+	return grpcorca.CallMetricsServerOption(nil)
+}
+
+// UnaryServerInterceptor counts the calls and records the latest sample to the per-call recorder when the trailers
+// are enabled. The in-process calls have no recorder.
+func (r *Reporter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		r.calls.Add(1)
+
+		resp, err := handler(ctx, req)
+
+		if r.cfg.Trailers {
+			if rec := grpcorca.CallMetricsRecorderFromContext(ctx); rec != nil {
+				r.record(rec)
+			}
+		}
+
+		return resp, err
+	}
+}
+
+// record copies the latest sample to the per-call recorder, the unset metrics are skipped.
+func (r *Reporter) record(rec grpcorca.CallMetricsRecorder) {
+	m := r.ServerMetrics()
+
+	if m.CPUUtilization != -1 {
+		rec.SetCPUUtilization(m.CPUUtilization)
+	}
+	if m.MemUtilization != -1 {
+		rec.SetMemoryUtilization(m.MemUtilization)
+	}
+	if m.AppUtilization != -1 {
+		rec.SetApplicationUtilization(m.AppUtilization)
+	}
+	if m.QPS != -1 {
+		rec.SetQPS(m.QPS)
+	}
+
+	for name, v := range m.Utilization {
+		rec.SetNamedUtilization(name, v)
+	}
+	for name, v := range m.NamedMetrics {
+		rec.SetNamedMetric(name, v)
+	}
+}
+
+// Register registers the xds.service.orca.v3.OpenRcaService streaming the reporter samples on the server.
+func Register(server *grpc.Server, r *Reporter) error {
+	return grpcorca.Register(server, grpcorca.ServiceOptions{
+		ServerMetricsProvider: r,
+		MinReportingInterval:  r.cfg.MinReportInterval,
+	})
+}
@@ -0,0 +1,175 @@
+package orca
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	v3orcapb "github.com/cncf/xds/go/xds/data/orca/v3"
+	v3orcaservicepb "github.com/cncf/xds/go/xds/service/orca/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func testLoad() Load {
+	return Load{Workers: 4, Busy: 3, Queued: 2}
+}
+
+func serve(t *testing.T, cfg *Config) *grpc.ClientConn {
+	t.Helper()
+
+	r := NewReporter(cfg, testLoad)
+	server := grpc.NewServer(r.ServerOption(), grpc.ChainUnaryInterceptor(r.UnaryServerInterceptor()))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	require.NoError(t, Register(server, r))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func assertLoad(t *testing.T, r *v3orcapb.OrcaLoadReport) {
+	t.Helper()
+	assert.Equal(t, 0.75, r.GetApplicationUtilization())
+	assert.Equal(t, 0.75, r.GetUtilization()["workers"])
+	assert.Equal(t, float64(4), r.GetNamedMetrics()["workers_total"])
+	assert.Equal(t, float64(3), r.GetNamedMetrics()["workers_busy"])
+	assert.Equal(t, float64(2), r.GetNamedMetrics()["queue_depth"])
+}
+
+func TestTrailers(t *testing.T) {
+	conn := serve(t, &Config{Trailers: true, MinReportInterval: time.Second * 30})
+
+	var trailer metadata.MD
+	_, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Trailer(&trailer))
+	require.NoError(t, err)
+
+	require.Len(t, trailer.Get(TrailerKey), 1)
+	r := &v3orcapb.OrcaLoadReport{}
+	require.NoError(t, proto.Unmarshal([]byte(trailer.Get(TrailerKey)[0]), r))
+	assertLoad(t, r)
+	assert.Positive(t, r.GetMemUtilization())
+}
+
+func TestTrailersFilledByInterceptor(t *testing.T) {
+	// the server option alone sends nothing, the recorder is filled by the interceptor
+	r := NewReporter(&Config{Trailers: true}, testLoad)
+	server := grpc.NewServer(r.ServerOption())
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	var trailer metadata.MD
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Trailer(&trailer))
+	require.NoError(t, err)
+	assert.Empty(t, trailer.Get(TrailerKey))
+}
+
+func TestNoTrailers(t *testing.T) {
+	conn := serve(t, &Config{OutOfBand: true, MinReportInterval: time.Second * 30})
+
+	var trailer metadata.MD
+	_, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Trailer(&trailer))
+	require.NoError(t, err)
+	assert.Empty(t, trailer.Get(TrailerKey))
+}
+
+func TestStreamCoreMetrics(t *testing.T) {
+	conn := serve(t, &Config{OutOfBand: true, MinReportInterval: time.Second * 30})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	stream, err := v3orcaservicepb.NewOpenRcaServiceClient(conn).StreamCoreMetrics(ctx, &v3orcaservicepb.OrcaLoadReportRequest{})
+	require.NoError(t, err)
+
+	// the first report is sent right away
+	r, err := stream.Recv()
+	require.NoError(t, err)
+	assertLoad(t, r)
+}
+
+func TestServerMetricsRefreshedInBackground(t *testing.T) {
+	release := make(chan struct{})
+	sampled := make(chan struct{}, 2)
+
+	var n int
+	r := NewReporter(&Config{Trailers: true}, func() Load {
+		n++
+		if n > 1 {
+			<-release
+		}
+		sampled <- struct{}{}
+		return Load{Workers: n, Busy: 1}
+	})
+
+	// the first sample is taken in place
+	m := r.ServerMetrics()
+	assert.Equal(t, float64(1), m.NamedMetrics["workers_total"])
+	<-sampled
+
+	// the callers merge into the returned copy
+	m.NamedMetrics["workers_total"] = 10
+	assert.Equal(t, float64(1), r.ServerMetrics().NamedMetrics["workers_total"])
+
+	// the stale sample is returned while the new one is taken
+	prev := r.last.Load()
+	r.last.Store(&sample{metrics: prev.metrics, taken: prev.taken.Add(-sampleInterval), calls: prev.calls})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Equal(t, float64(1), r.ServerMetrics().NamedMetrics["workers_total"])
+		// a single refresh at a time
+		assert.Equal(t, float64(1), r.ServerMetrics().NamedMetrics["workers_total"])
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("the call waited for the sample")
+	}
+
+	close(release)
+	<-sampled
+
+	require.Eventually(t, func() bool {
+		return r.ServerMetrics().NamedMetrics["workers_total"] == 2
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestConfigInitDefaults(t *testing.T) {
+	c := &Config{}
+	require.NoError(t, c.InitDefaults())
+	assert.True(t, c.Trailers)
+	assert.True(t, c.OutOfBand)
+	assert.Equal(t, time.Second*30, c.MinReportInterval)
+
+	c = &Config{OutOfBand: true}
+	require.NoError(t, c.InitDefaults())
+	assert.False(t, c.Trailers)
+
+	assert.Error(t, (&Config{MinReportInterval: -time.Second}).InitDefaults())
+	assert.Error(t, (&Config{MinReportInterval: time.Second}).InitDefaults())
+}
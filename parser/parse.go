@@ -37,6 +37,9 @@ type Method struct {
 
 	// ReturnsType defines the message name (from the same package) of the method return value.
 	ReturnsType string
+
+	// NoSideEffects defines if the method is marked with the NO_SIDE_EFFECTS idempotency level.
+	NoSideEffects bool
 }
 
 // File parses given proto file or returns error.
@@ -112,9 +115,21 @@ func parseMethods(s *pp.Service) []Method {
 				RequestType:    m.RequestType,
 				StreamsReturns: m.StreamsReturns,
 				ReturnsType:    m.ReturnsType,
+				NoSideEffects:  noSideEffects(m),
 			})
 		}
 	}
 
 	return methods
 }
+
+// noSideEffects checks the `option idempotency_level = NO_SIDE_EFFECTS;` method option.
+func noSideEffects(m *pp.RPC) bool {
+	for _, e := range m.Elements {
+		if o, ok := e.(*pp.Option); ok && o.Name == "idempotency_level" {
+			return o.Constant.Source == "NO_SIDE_EFFECTS"
+		}
+	}
+
+	return false
+}
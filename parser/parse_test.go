@@ -74,3 +74,31 @@ message Message {
 
 	assert.Equal(t, "app.namespace", services[0].Package)
 }
+
+func TestParseNoSideEffects(t *testing.T) {
+	services, err := Bytes([]byte(`
+syntax = "proto3";
+package app.namespace;
+
+service ItemService {
+   rpc Get (Message) returns (Message) {
+      option idempotency_level = NO_SIDE_EFFECTS;
+   }
+   rpc Put (Message) returns (Message) {
+      option idempotency_level = IDEMPOTENT;
+   }
+   rpc Create (Message) returns (Message);
+}
+
+message Message {
+   string msg = 1;
+}
+`))
+	assert.NoError(t, err)
+	assert.Len(t, services, 1)
+	assert.Len(t, services[0].Methods, 3)
+
+	assert.True(t, services[0].Methods[0].NoSideEffects)
+	assert.False(t, services[0].Methods[1].NoSideEffects)
+	assert.False(t, services[0].Methods[2].NoSideEffects)
+}
@@ -3,12 +3,14 @@ package grpc
 
 import (
 	"context"
-	stderr "errors"
 	"log/slog"
+	"net"
+	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/roadrunner-server/tcplisten"
 	"go.opentelemetry.io/otel/propagation"
 
 	jprop "go.opentelemetry.io/contrib/propagators/jaeger"
@@ -17,7 +19,12 @@ import (
 	"github.com/roadrunner-server/endure/v2/dep"
 	"github.com/roadrunner-server/errors"
 	"github.com/roadrunner-server/grpc/v6/api"
+	"github.com/roadrunner-server/grpc/v6/certs"
 	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/grpc/v6/compression"
+	"github.com/roadrunner-server/grpc/v6/inherit"
+	"github.com/roadrunner-server/grpc/v6/limits"
+	"github.com/roadrunner-server/grpc/v6/operations"
 	"github.com/roadrunner-server/grpc/v6/proxy"
 	"github.com/roadrunner-server/pool/v2/pool"
 	"github.com/roadrunner-server/pool/v2/state/process"
@@ -37,19 +44,47 @@ const (
 	RrMode     string = "RR_MODE"
 )
 
+// stopGrace limits the wait for the background calls (async and long-running) still running after the stop
+// context is done and they are canceled, so a worker call ignoring the cancellation does not block the shutdown.
+const stopGrace = time.Second * 5
+
 type Tracer interface {
 	Tracer() *sdktrace.TracerProvider
 }
 
 type Plugin struct {
-	mu           *sync.RWMutex
-	config       *Config
-	gPool        api.Pool
-	opts         []grpc.ServerOption
-	server       *grpc.Server
-	rrServer     api.Server
-	proxyList    []*proxy.Proxy
-	healthServer *HealthCheckServer
+	mu     *sync.RWMutex
+	config *Config
+	gPool  api.Pool
+	opts   []grpc.ServerOption
+	server *grpc.Server
+	// httpSrv serves the gRPC server through net/http when gRPC-Web or Connect is enabled
+	httpSrv *http.Server
+	// probesSrv serves the HTTP probes on the HTTP/1.1 connections split from the gRPC listeners
+	probesSrv       *http.Server
+	probesListeners []net.Listener
+	// transcodingSrv serves the google.api.http bindings on a separate listener
+	transcodingSrv *http.Server
+	// unaryInterceptors are the server interceptors, the in-process calls are made through them too
+	unaryInterceptors []grpc.UnaryServerInterceptor
+	rrServer          api.Server
+	proxyList         []*proxy.Proxy
+	healthServer      *HealthCheckServer
+	// async executes the fire-and-forget calls, drained on Stop
+	async *proxy.AsyncDispatcher
+	// operations keeps the long-running calls, drained on Stop
+	operations *operations.Store
+	// inFlight is the number of the unary calls being served, reported during the drain
+	inFlight atomic.Int64
+	// certReloaders watch the TLS files of the listeners
+	certReloaders []*certs.Reloader
+	// handoff hands the listeners over to the successor process
+	handoff *inherit.Server
+	// adminSrv serves the admin services on the separate listener, adminCleanup releases the admin services
+	adminSrv     *grpc.Server
+	adminCleanup func()
+	// limiter rejects the excessive connections and streams, shared between the listeners
+	limiter *limits.Limiter
 
 	statsExporter *StatsExporter
 	prop          propagation.TextMapPropagator
@@ -58,6 +93,15 @@ type Plugin struct {
 	queueSize       prometheus.Gauge
 	requestCounter  *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
+	cacheRequests   *prometheus.CounterVec
+	// proxyProtocolConns counts the connections by the PROXY header decoding result
+	proxyProtocolConns *prometheus.CounterVec
+	// certExpiry is the expiry time of the served TLS certificates
+	certExpiry *prometheus.GaugeVec
+	// limitRejections counts the connections and streams rejected by the limits
+	limitRejections *prometheus.CounterVec
+	// sizeLimitRejections counts the requests rejected by the decompressed size limits
+	sizeLimitRejections *prometheus.CounterVec
 
 	log *slog.Logger
 
@@ -128,6 +172,47 @@ func (p *Plugin) Init(cfg api.Configurer, log api.Logger, server api.Server) err
 		[]string{"grpc_method"},
 	)
 
+	p.cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Total number of response cache lookups, by result (hit or miss).",
+	}, []string{"grpc_method", "result"})
+
+	p.proxyProtocolConns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "proxy_protocol_connections_total",
+		Help:      "Total number of accepted connections, by the PROXY header decoding result (v1, v2, local, none, untrusted or error).",
+	}, []string{"result"})
+
+	p.certExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tls_certificate_expiry_timestamp_seconds",
+		Help:      "Expiry time (not after) of the served TLS certificate, by the certificate file.",
+	}, []string{"cert"})
+
+	p.limitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "limit_rejections_total",
+		Help:      "Total number of connections and streams rejected by the limits, by reason (max_connections, max_connections_per_ip or stream_rate).",
+	}, []string{"reason"})
+
+	p.sizeLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "size_limit_rejections_total",
+		Help:      "Total number of requests rejected by the decompressed size limits, by method.",
+	}, []string{"grpc_method"})
+
+	if p.config.Compression != nil {
+		err = compression.Register(p.config.Compression)
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if p.config.Limits != nil {
+		p.limiter = limits.NewLimiter(p.config.Limits, p.limitRejections)
+	}
+
 	p.prop = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}, jprop.Jaeger{})
 	p.tracer = sdktrace.NewTracerProvider()
 	p.interceptors = make(map[string]api.Interceptor)
@@ -168,7 +253,13 @@ func (p *Plugin) Serve() chan error {
 		return errCh
 	}
 
-	l, err := tcplisten.CreateListener(p.config.Listen)
+	if p.config.GrpcWeb != nil || p.config.Connect != nil {
+		p.httpSrv = p.httpServer()
+	} else if p.config.HTTPProbes != nil {
+		p.probesSrv = p.probesServer()
+	}
+
+	listeners, err := p.listen()
 	if err != nil {
 		errCh <- errors.E(op, err)
 		return errCh
@@ -179,23 +270,23 @@ func (p *Plugin) Serve() chan error {
 
 	p.registerReflection()
 
-	go func() {
-		p.log.Info("grpc server was started", "address", p.config.Listen)
-
-		p.healthServer.SetServingStatus(grpc_health_v1.HealthCheckResponse_SERVING)
-		err = p.server.Serve(l)
-		p.healthServer.Shutdown()
+	if p.config.Admin != nil {
+		err = p.serveAdmin(errCh)
 		if err != nil {
-			// skip errors when stopping the server
-			if stderr.Is(err, grpc.ErrServerStopped) {
-				return
-			}
+			errCh <- errors.E(op, err)
+			return errCh
+		}
+	}
 
-			p.log.Error("grpc server was stopped", "error", err)
+	if p.config.HTTPTranscoding != nil {
+		err = p.serveTranscoding(errCh)
+		if err != nil {
 			errCh <- errors.E(op, err)
-			return
+			return errCh
 		}
-	}()
+	}
+
+	p.serve(listeners, errCh)
 
 	return errCh
 }
@@ -225,22 +316,45 @@ func (p *Plugin) registerReflection() {
 func (p *Plugin) Stop(ctx context.Context) error {
 	finCh := make(chan struct{}, 1)
 	go func() {
+		p.drain(ctx)
+
+		// the queued calls and running operations still need workers, the worker calls take p.mu,
+		// so they are stopped before the lock is held
+		if p.async != nil {
+			err := p.async.Stop(ctx, stopGrace)
+			if err != nil {
+				p.log.Error("async queue was not drained", "error", err)
+			}
+		}
+
+		if p.operations != nil {
+			err := p.operations.Stop(ctx, stopGrace)
+			if err != nil {
+				p.log.Error("running operations were not finished", "error", err)
+			}
+		}
+
 		p.mu.Lock()
 		defer p.mu.Unlock()
 
-		if p.healthServer != nil {
-			p.healthServer.SetServingStatus(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		if p.handoff != nil {
+			_ = p.handoff.Close()
 		}
 
-		if p.server != nil {
-			p.server.GracefulStop()
+		if p.adminCleanup != nil {
+			p.adminCleanup()
 		}
 
 		if p.healthServer != nil {
 			p.healthServer.Shutdown()
 		}
 
+		for _, r := range p.certReloaders {
+			r.Stop()
+		}
+
 		if p.gPool != nil {
+			p.log.Info("drain: destroying the workers pool", "in_flight", p.inFlight.Load())
 			p.gPool.Destroy(ctx)
 		}
 
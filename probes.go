@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/roadrunner-server/api-plugins/v6/status"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// probesServer serves the HTTP/1.1 connections split from the plaintext gRPC listeners.
+func (p *Plugin) probesServer() *http.Server {
+	return &http.Server{
+		Handler:           p.probesHandler(http.NotFoundHandler()),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+}
+
+// probesHandler serves the liveness, readiness and metrics paths, other requests (gRPC among them) are passed
+// to next.
+func (p *Plugin) probesHandler(next http.Handler) http.Handler {
+	cfg := p.config.HTTPProbes
+
+	var metrics http.Handler
+	if cfg.Metrics != "" {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(p.MetricsCollector()...)
+		metrics = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.URL.Path {
+		case cfg.Liveness:
+			writeStatus(w, p.Status)
+		case cfg.Readiness:
+			// not ready while draining
+			if !p.serving() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeStatus(w, p.Ready)
+		case cfg.Metrics:
+			if metrics == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			metrics.ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// serving reports whether the health of the server is SERVING, true before the health server is created. The
+// health server is assigned by Serve under the plugin lock.
+func (p *Plugin) serving() bool {
+	p.mu.RLock()
+	health := p.healthServer
+	p.mu.RUnlock()
+
+	if health == nil {
+		return true
+	}
+
+	resp, err := health.Check(context.Background(), nil)
+	return err == nil && resp.GetStatus() == grpc_health_v1.HealthCheckResponse_SERVING
+}
+
+func writeStatus(w http.ResponseWriter, probe func() (*status.Status, error)) {
+	st, err := probe()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(st.Code)
+}
@@ -0,0 +1,154 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/pool/v2/fsm"
+	"github.com/roadrunner-server/pool/v2/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHTTPProbes(t *testing.T) {
+	cfg := &Config{Listen: "127.0.0.1:0", HTTPProbes: &HTTPProbes{Metrics: "/metrics"}}
+	require.NoError(t, cfg.InitDefaults())
+
+	p := &Plugin{
+		config: cfg,
+		mu:     &sync.RWMutex{},
+		log:    slog.New(slog.DiscardHandler),
+		gPool:  &fakeStatusPool{workers: []*worker.Process{newWorker(t, fsm.StateReady)}},
+
+		queueSize:           prometheus.NewGauge(prometheus.GaugeOpts{Name: "q"}),
+		requestCounter:      prometheus.NewCounterVec(prometheus.CounterOpts{Name: "c"}, []string{"grpc_method", "status_code"}),
+		requestDuration:     prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "d"}, []string{"grpc_method"}),
+		cacheRequests:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "cr"}, []string{"l"}),
+		proxyProtocolConns:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "pp"}, []string{"l"}),
+		certExpiry:          prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "ce"}, []string{"l"}),
+		limitRejections:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lr"}, []string{"l"}),
+		sizeLimitRejections: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "sr"}, []string{"l"}),
+	}
+	p.statsExporter = newStatsExporter(&fakeInformer{})
+
+	p.server = grpc.NewServer(grpc.ChainUnaryInterceptor(p.interceptor))
+	p.healthServer = NewHeathServer(p, p.log)
+	p.healthServer.RegisterServer(p.server)
+	p.probesSrv = p.probesServer()
+
+	listeners, err := p.listen()
+	require.NoError(t, err)
+	require.Len(t, p.probesListeners, 1)
+	p.serve(listeners, make(chan error, 1))
+	t.Cleanup(p.server.Stop)
+
+	address := listeners[0].Addr().String()
+	get := func(path string) (int, string) {
+		resp, err := http.Get("http://" + address + path) //nolint:noctx
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	code, _ := get("/health")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = get("/ready")
+	assert.Equal(t, http.StatusOK, code)
+	code, body := get("/metrics")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "q 0")
+	code, _ = get("/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// gRPC is served on the same port
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	// not ready while draining
+	p.healthServer.SetServingStatus(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	code, _ = get("/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, _ = get("/health")
+	assert.Equal(t, http.StatusOK, code)
+
+	p.drain(context.Background())
+
+	_, err = http.Get("http://" + address + "/health") //nolint:noctx
+	assert.Error(t, err)
+}
+
+func TestHTTPProbesHandler(t *testing.T) {
+	// gRPC-Web and Connect requests are passed to the next handler
+	p := &Plugin{
+		config: &Config{HTTPProbes: &HTTPProbes{Liveness: "/health", Readiness: "/ready"}},
+		mu:     &sync.RWMutex{},
+		gPool:  &fakeStatusPool{},
+	}
+
+	var passed int
+	h := p.probesHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { passed++ }))
+
+	req := httptest.NewRequest(http.MethodPost, "/health", nil)
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, 1, passed)
+
+	// no workers
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, 1, passed)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, 2, passed)
+}
+
+func TestHTTPProbesReadinessDuringServe(t *testing.T) {
+	p := &Plugin{
+		config: &Config{HTTPProbes: &HTTPProbes{Liveness: "/health", Readiness: "/ready"}},
+		mu:     &sync.RWMutex{},
+		gPool:  &fakeStatusPool{workers: []*worker.Process{newWorker(t, fsm.StateReady)}},
+	}
+	h := p.probesHandler(http.NotFoundHandler())
+
+	// the health server is created while the probes are served
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.mu.Lock()
+		p.healthServer = NewHeathServer(p, p.log)
+		p.mu.Unlock()
+	}()
+
+	for range 10 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ready", nil))
+	}
+	<-done
+
+	// NOT_SERVING until the listeners are served
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	p.healthServer.SetServingStatus(grpc_health_v1.HealthCheckResponse_SERVING)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,351 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+	"github.com/roadrunner-server/pool/v2/payload"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// AsyncConfig configures the fire-and-forget methods. The calls to these methods are acknowledged immediately
+// with an empty message (the response type with all the fields unset, it is not configurable), the worker call
+// is executed in the background.
+type AsyncConfig struct {
+	// Methods are the fully-qualified method names, e.g. `package.Service/Method`.
+	Methods []string `mapstructure:"methods"`
+	// QueueSize limits the number of the queued calls, the calls over the limit are rejected. Defaults to 1000.
+	QueueSize int `mapstructure:"queue_size"`
+	// Concurrency is the number of the calls executed in parallel. Defaults to 1.
+	Concurrency int `mapstructure:"concurrency"`
+	// Retries is the number of the additional attempts for a failed call. Defaults to 3.
+	Retries *int `mapstructure:"retries"`
+	// RetryDelay is the delay before the first retry, doubled for every next one. Defaults to 1s.
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+	// Timeout limits a single attempt. Defaults to 1m.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// DeadLetter is the file the calls are appended to (as JSON lines) after all the attempts failed.
+	// The failed calls are only logged when empty.
+	DeadLetter string `mapstructure:"dead_letter"`
+}
+
+func (c *AsyncConfig) InitDefaults() error {
+	const op = errors.Op("grpc_async_init")
+
+	for i := range c.Methods {
+		var err error
+		c.Methods[i], err = methodname.Normalize(c.Methods[i])
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.QueueSize == 0 {
+		c.QueueSize = 1000
+	}
+
+	if c.Concurrency == 0 {
+		c.Concurrency = 1
+	}
+
+	if c.Retries == nil {
+		retries := 3
+		c.Retries = &retries
+	}
+
+	if c.RetryDelay == 0 {
+		c.RetryDelay = time.Second
+	}
+
+	if c.Timeout == 0 {
+		c.Timeout = time.Minute
+	}
+
+	if c.QueueSize < 0 || c.Concurrency < 0 || *c.Retries < 0 || c.RetryDelay < 0 || c.Timeout < 0 {
+		return errors.E(op, errors.Str("queue_size, concurrency, retries, retry_delay and timeout should not be negative"))
+	}
+
+	return nil
+}
+
+// AsyncDispatcher executes the queued fire-and-forget calls in the background.
+type AsyncDispatcher struct {
+	log     *slog.Logger
+	cfg     *AsyncConfig
+	methods map[string]struct{}
+
+	// guards the queue against sending after Stop
+	mu      sync.RWMutex
+	stopped bool
+	queue   chan *asyncCall
+	wg      sync.WaitGroup
+
+	// canceled when Stop's context is done, interrupts retries
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	dlMu       sync.Mutex
+	deadLetter *os.File
+}
+
+type asyncCall struct {
+	proxy  *Proxy
+	method string
+	pld    *payload.Payload
+}
+
+// deadLetterRecord is a single line of the dead-letter file.
+type deadLetterRecord struct {
+	Time    time.Time       `json:"time"`
+	Method  string          `json:"method"`
+	Context json.RawMessage `json:"context"`
+	Body    []byte          `json:"body"`
+	Error   string          `json:"error"`
+}
+
+// NewAsyncDispatcher creates the dispatcher and starts its background workers.
+func NewAsyncDispatcher(cfg *AsyncConfig, log *slog.Logger) (*AsyncDispatcher, error) {
+	const op = errors.Op("grpc_async_dispatcher")
+
+	d := &AsyncDispatcher{
+		log:     log,
+		cfg:     cfg,
+		methods: make(map[string]struct{}, len(cfg.Methods)),
+		queue:   make(chan *asyncCall, cfg.QueueSize),
+	}
+
+	for _, m := range cfg.Methods {
+		d.methods[m] = struct{}{}
+	}
+
+	if cfg.DeadLetter != "" {
+		f, err := os.OpenFile(cfg.DeadLetter, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		d.deadLetter = f
+	}
+
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	for range cfg.Concurrency {
+		d.wg.Go(d.work)
+	}
+
+	return d, nil
+}
+
+// SetAsync assigns the dispatcher executing the configured fire-and-forget methods.
+func (p *Proxy) SetAsync(d *AsyncDispatcher) {
+	p.async = d
+}
+
+func (p *Proxy) isAsync(method string) bool {
+	if p.async == nil {
+		return false
+	}
+
+	_, ok := p.async.methods[p.name+"/"+method]
+	return ok
+}
+
+// enqueue schedules the call for the background execution and acknowledges it with an empty message.
+func (p *Proxy) enqueue(ctx context.Context, method string, in *codec.RawMessage) (any, error) {
+	pld, err := p.detachedPayload(ctx, method, in)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.async.push(&asyncCall{proxy: p, method: p.name + "/" + method, pld: pld})
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.RawMessage{}, nil
+}
+
+func (d *AsyncDispatcher) push(call *asyncCall) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.stopped {
+		return status.Error(codes.Unavailable, "server is stopping")
+	}
+
+	select {
+	case d.queue <- call:
+		return nil
+	default:
+		return status.Error(codes.ResourceExhausted, "async queue is full")
+	}
+}
+
+func (d *AsyncDispatcher) work() {
+	for call := range d.queue {
+		d.execute(call)
+	}
+}
+
+// execute runs the call with retries and writes it to the dead-letter log when all the attempts failed.
+func (d *AsyncDispatcher) execute(call *asyncCall) {
+	var err error
+	delay := d.cfg.RetryDelay
+
+	for attempt := 0; attempt <= *d.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			d.log.Warn("retrying async call", "method", call.method, "attempt", attempt, "error", err)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-d.ctx.Done():
+				timer.Stop()
+				d.dead(call, d.ctx.Err())
+				return
+			}
+			delay *= 2
+		}
+
+		err = d.attempt(call)
+		if err == nil {
+			return
+		}
+	}
+
+	d.dead(call, err)
+}
+
+func (d *AsyncDispatcher) attempt(call *asyncCall) error {
+	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.Timeout)
+	defer cancel()
+
+	resp, err := call.proxy.execPayload(ctx, call.pld)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp.context)
+}
+
+// responseError returns the error (PHP exception) sent by the worker in the response context.
+func responseError(rctx []byte) error {
+	if len(rctx) == 0 {
+		return nil
+	}
+
+	var rpcMetadata map[string]string
+	err := json.Unmarshal(rctx, &rpcMetadata)
+	if err != nil {
+		return err
+	}
+
+	e, ok := rpcMetadata[apiErr]
+	if !ok {
+		return nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(e)
+	if err != nil {
+		return err
+	}
+
+	sst := &spb.Status{}
+	err = proto.Unmarshal(data, sst)
+	if err != nil {
+		return err
+	}
+
+	return status.ErrorProto(sst)
+}
+
+func (d *AsyncDispatcher) dead(call *asyncCall, err error) {
+	d.log.Error("async call failed", "method", call.method, "error", err)
+
+	data, errM := json.Marshal(&deadLetterRecord{
+		Time:    time.Now(),
+		Method:  call.method,
+		Context: call.pld.Context,
+		Body:    call.pld.Body,
+		Error:   err.Error(),
+	})
+	if errM != nil {
+		d.log.Error("failed to marshal dead letter", "method", call.method, "error", errM)
+		return
+	}
+
+	d.dlMu.Lock()
+	defer d.dlMu.Unlock()
+
+	// closed by Stop while a worker call ignored the cancellation
+	if d.deadLetter == nil {
+		if d.cfg.DeadLetter != "" {
+			d.log.Error("dead-letter log is closed, async call was dropped", "method", call.method, "dead_letter", string(data))
+		}
+		return
+	}
+
+	_, errW := d.deadLetter.Write(append(data, '\n'))
+	if errW != nil {
+		d.log.Error("failed to write dead letter", "method", call.method, "error", errW)
+	}
+}
+
+// Stop rejects the new calls and waits for the queued ones to finish. When ctx is done, the pending retries
+// are interrupted and the remaining calls are written to the dead-letter log. The calls still running after
+// the grace period following the cancellation are logged as dropped once they finish.
+func (d *AsyncDispatcher) Stop(ctx context.Context, grace time.Duration) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.stopped = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	d.log.Info("draining async queue", "queued", len(d.queue))
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.cancel()
+		err = ctx.Err()
+
+		select {
+		case <-done:
+		case <-time.After(grace):
+			d.log.Error("async calls were not finished after the cancellation", "queued", len(d.queue))
+
+			// the queued calls are not reached by the busy workers
+			for call := range d.queue {
+				d.dead(call, err)
+			}
+		}
+	}
+	d.cancel()
+
+	d.dlMu.Lock()
+	if d.deadLetter != nil {
+		_ = d.deadLetter.Close()
+		d.deadLetter = nil
+	}
+	d.dlMu.Unlock()
+
+	return err
+}
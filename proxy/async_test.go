@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/pool/v2/payload"
+	"github.com/roadrunner-server/pool/v2/pool/static_pool"
+	"github.com/roadrunner-server/pool/v2/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failingPool is a Pool which fails every Exec call, optionally blocking until released.
+type failingPool struct {
+	calls   atomic.Int32
+	release chan struct{}
+	// the blocked calls are not interrupted by the context
+	ignoreCancel bool
+}
+
+func (f *failingPool) Workers() []*worker.Process { return nil }
+func (f *failingPool) Exec(ctx context.Context, _ *payload.Payload, _ chan struct{}) (chan *static_pool.PExec, error) {
+	f.calls.Add(1)
+	if f.release != nil && f.ignoreCancel {
+		<-f.release
+	} else if f.release != nil {
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, errors.Str("no free workers")
+}
+func (f *failingPool) Reset(context.Context) error { return nil }
+func (f *failingPool) Destroy(context.Context)     {}
+
+func newAsyncProxy(t *testing.T, cfg *AsyncConfig, pool Pool) (*Proxy, *AsyncDispatcher) {
+	t.Helper()
+	require.NoError(t, cfg.InitDefaults())
+
+	d, err := NewAsyncDispatcher(cfg, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+
+	p := newTestProxy("app.Service")
+	p.grpcPool = pool
+	p.SetAsync(d)
+
+	return p, d
+}
+
+func TestAsyncConfigInitDefaults(t *testing.T) {
+	cfg := &AsyncConfig{Methods: []string{"/app.Service/Ingest"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, []string{"app.Service/Ingest"}, cfg.Methods)
+	assert.Equal(t, 1000, cfg.QueueSize)
+	assert.Equal(t, 1, cfg.Concurrency)
+	assert.Equal(t, 3, *cfg.Retries)
+	assert.Equal(t, time.Second, cfg.RetryDelay)
+
+	zero := 0
+	cfg = &AsyncConfig{Retries: &zero}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, 0, *cfg.Retries)
+
+	assert.Error(t, (&AsyncConfig{Methods: []string{"Ingest"}}).InitDefaults())
+	assert.Error(t, (&AsyncConfig{QueueSize: -1}).InitDefaults())
+}
+
+func TestAsyncRetriesAndDeadLetter(t *testing.T) {
+	dl := filepath.Join(t.TempDir(), "dead.jsonl")
+	retries := 2
+	pool := &failingPool{}
+	p, d := newAsyncProxy(t, &AsyncConfig{
+		Methods:    []string{"app.Service/Ingest"},
+		Retries:    &retries,
+		RetryDelay: time.Millisecond,
+		DeadLetter: dl,
+	}, pool)
+
+	require.True(t, p.isAsync("Ingest"))
+	require.False(t, p.isAsync("Get"))
+
+	in := codec.RawMessage("event")
+	resp, err := p.enqueue(context.Background(), "Ingest", &in)
+	require.NoError(t, err)
+	assert.Equal(t, codec.RawMessage{}, resp)
+
+	require.NoError(t, d.Stop(context.Background(), time.Second))
+	assert.Equal(t, int32(3), pool.calls.Load())
+
+	f, err := os.Open(dl)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	sc := bufio.NewScanner(f)
+	require.True(t, sc.Scan())
+
+	rec := &deadLetterRecord{}
+	require.NoError(t, json.Unmarshal(sc.Bytes(), rec))
+	assert.Equal(t, "app.Service/Ingest", rec.Method)
+	assert.Equal(t, []byte("event"), rec.Body)
+	assert.Contains(t, string(rec.Context), `"method":"Ingest"`)
+	assert.False(t, sc.Scan())
+}
+
+func TestAsyncQueueFullAndStopped(t *testing.T) {
+	pool := &failingPool{release: make(chan struct{})}
+	zero := 0
+	p, d := newAsyncProxy(t, &AsyncConfig{
+		Methods:   []string{"app.Service/Ingest"},
+		QueueSize: 1,
+		Retries:   &zero,
+	}, pool)
+
+	in := codec.RawMessage("event")
+	// the first call is taken by the worker, the second one fills the queue
+	_, err := p.enqueue(context.Background(), "Ingest", &in)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return pool.calls.Load() == 1 }, time.Second, time.Millisecond)
+	_, err = p.enqueue(context.Background(), "Ingest", &in)
+	require.NoError(t, err)
+
+	_, err = p.enqueue(context.Background(), "Ingest", &in)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	close(pool.release)
+	require.NoError(t, d.Stop(context.Background(), time.Second))
+
+	_, err = p.enqueue(context.Background(), "Ingest", &in)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestAsyncStopDeadline(t *testing.T) {
+	pool := &failingPool{release: make(chan struct{})}
+	defer close(pool.release)
+
+	p, d := newAsyncProxy(t, &AsyncConfig{Methods: []string{"app.Service/Ingest"}}, pool)
+
+	in := codec.RawMessage("event")
+	_, err := p.enqueue(context.Background(), "Ingest", &in)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	// the blocked call is interrupted when the drain deadline is reached
+	assert.ErrorIs(t, d.Stop(ctx, time.Millisecond*50), context.DeadlineExceeded)
+}
+
+func TestAsyncStopGrace(t *testing.T) {
+
+	dl := filepath.Join(t.TempDir(), "dead.jsonl")
+	pool := &failingPool{release: make(chan struct{}), ignoreCancel: true}
+	defer close(pool.release)
+
+	p, d := newAsyncProxy(t, &AsyncConfig{Methods: []string{"app.Service/Ingest"}, DeadLetter: dl}, pool)
+
+	in := codec.RawMessage("event")
+	_, err := p.enqueue(context.Background(), "Ingest", &in)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return pool.calls.Load() == 1 }, time.Second, time.Millisecond*5)
+
+	// queued behind the blocked call
+	queued := codec.RawMessage("queued")
+	_, err = p.enqueue(context.Background(), "Ingest", &queued)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	// a call ignoring the cancellation does not block Stop forever
+	start := time.Now()
+	assert.ErrorIs(t, d.Stop(ctx, time.Millisecond*50), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), pool.calls.Load())
+
+	// the queued call is written to the dead-letter log before it is closed
+	data, err := os.ReadFile(dl)
+	require.NoError(t, err)
+
+	rec := &deadLetterRecord{}
+	require.NoError(t, json.Unmarshal(data, rec))
+	assert.Equal(t, []byte("queued"), rec.Body)
+}
+
+func TestResponseError(t *testing.T) {
+	assert.NoError(t, responseError(nil))
+	assert.NoError(t, responseError([]byte(`{"headers":"{}"}`)))
+
+	// code 8 (ResourceExhausted), message "fail"
+	err := responseError([]byte(`{"error":"CAgSBGZhaWw="}`))
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,272 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BatchingConfig configures the micro-batching. The concurrent calls to the configured methods are grouped into
+// a single worker call.
+//
+// The worker receives a batch context, `{"service": "...", "method": "...", "batch": [rpcContext, ...]}`, and a JSON
+// array of the base64-encoded request bodies in the same order. It should respond with a JSON array of
+// `{"body": "<base64>", "context": {...}}` items, one per request, where the item context is the regular response
+// context (headers, trailers or error) of the call.
+type BatchingConfig struct {
+	// Methods are the fully-qualified method names, e.g. `package.Service/Method`.
+	Methods []string `mapstructure:"methods"`
+	// MaxItems is the maximum number of the calls in a batch. Defaults to 10.
+	MaxItems int `mapstructure:"max_items"`
+	// MaxWait is the time a batch waits for more calls after the first one. Defaults to 5ms.
+	MaxWait time.Duration `mapstructure:"max_wait"`
+}
+
+func (c *BatchingConfig) InitDefaults() error {
+	const op = errors.Op("grpc_batching_init")
+
+	for i := range c.Methods {
+		var err error
+		c.Methods[i], err = methodname.Normalize(c.Methods[i])
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.MaxItems == 0 {
+		c.MaxItems = 10
+	}
+
+	if c.MaxWait == 0 {
+		c.MaxWait = time.Millisecond * 5
+	}
+
+	if c.MaxItems < 0 || c.MaxWait < 0 {
+		return errors.E(op, errors.Str("max_items and max_wait should not be negative"))
+	}
+
+	return nil
+}
+
+// Batcher groups the concurrent calls to the same method into batches.
+type Batcher struct {
+	maxItems int
+	maxWait  time.Duration
+	methods  map[string]struct{}
+
+	mu      sync.Mutex
+	pending map[string]*batch
+}
+
+type batch struct {
+	proxy  *Proxy
+	method string
+	items  []*batchItem
+	timer  *time.Timer
+}
+
+type batchItem struct {
+	ctx      context.Context
+	rpcCtx   *rpcContext
+	body     []byte
+	resp     *response
+	err      error
+	finished chan struct{}
+}
+
+// batchContext is the context of the batched worker call.
+type batchContext struct {
+	Service string        `json:"service"`
+	Method  string        `json:"method"`
+	Batch   []*rpcContext `json:"batch"`
+}
+
+// batchResponse is a single response of the batched worker call.
+type batchResponse struct {
+	Body    []byte          `json:"body"`
+	Context json.RawMessage `json:"context"`
+}
+
+// NewBatcher creates the batcher for the configured methods.
+func NewBatcher(cfg *BatchingConfig) *Batcher {
+	b := &Batcher{
+		maxItems: cfg.MaxItems,
+		maxWait:  cfg.MaxWait,
+		methods:  make(map[string]struct{}, len(cfg.Methods)),
+		pending:  make(map[string]*batch),
+	}
+
+	for _, m := range cfg.Methods {
+		b.methods[m] = struct{}{}
+	}
+
+	return b
+}
+
+// SetBatching assigns the batcher grouping the calls of the configured methods.
+func (p *Proxy) SetBatching(b *Batcher) {
+	p.batcher = b
+}
+
+func (p *Proxy) isBatched(method string) bool {
+	if p.batcher == nil {
+		return false
+	}
+
+	_, ok := p.batcher.methods[p.name+"/"+method]
+	return ok
+}
+
+// batched adds the call to the pending batch of the method and waits for its own response.
+func (p *Proxy) batched(ctx context.Context, method string, in *codec.RawMessage) (*response, error) {
+	item := &batchItem{
+		ctx:      ctx,
+		rpcCtx:   p.rpcContext(ctx, method),
+		body:     *in,
+		finished: make(chan struct{}),
+	}
+
+	p.batcher.add(p, method, item)
+
+	select {
+	case <-item.finished:
+		return item.resp, item.err
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (b *Batcher) add(p *Proxy, method string, item *batchItem) {
+	key := p.name + "/" + method
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bt, ok := b.pending[key]
+	if !ok {
+		bt = &batch{proxy: p, method: method}
+		b.pending[key] = bt
+		bt.timer = time.AfterFunc(b.maxWait, func() {
+			b.mu.Lock()
+			// the batch might be already sent because it was full
+			if b.pending[key] == bt {
+				delete(b.pending, key)
+			}
+			b.mu.Unlock()
+
+			bt.flush()
+		})
+	}
+
+	bt.items = append(bt.items, item)
+	if len(bt.items) >= b.maxItems && bt.timer.Stop() {
+		delete(b.pending, key)
+		go bt.flush()
+	}
+}
+
+// flush executes the batch on a worker and fans the responses out to the callers.
+func (bt *batch) flush() {
+	ctx, cancel := bt.context()
+	defer cancel()
+
+	resps, err := bt.exec(ctx)
+	for i, item := range bt.items {
+		if err != nil {
+			item.err = err
+		} else {
+			item.resp = resps[i]
+		}
+		close(item.finished)
+	}
+}
+
+// context is canceled at the latest deadline of the calls, it has no deadline when any of the calls has none.
+func (bt *batch) context() (context.Context, context.CancelFunc) {
+	var deadline time.Time
+	for _, item := range bt.items {
+		d, ok := item.ctx.Deadline()
+		if !ok {
+			return context.WithCancel(context.Background())
+		}
+
+		if d.After(deadline) {
+			deadline = d
+		}
+	}
+
+	return context.WithDeadline(context.Background(), deadline)
+}
+
+func (bt *batch) exec(ctx context.Context) ([]*response, error) {
+	p := bt.proxy
+
+	bctx := &batchContext{Service: p.name, Method: bt.method, Batch: make([]*rpcContext, len(bt.items))}
+	bodies := make([][]byte, len(bt.items))
+	for i, item := range bt.items {
+		bctx.Batch[i] = item.rpcCtx
+		bodies[i] = item.body
+	}
+
+	pld := p.getPld()
+	defer p.putPld(pld)
+
+	var err error
+	pld.Context, err = json.Marshal(bctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pld.Body, err = json.Marshal(bodies)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.execPayload(ctx, pld)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitBatch(resp, len(bt.items))
+}
+
+// splitBatch splits the batched worker response into n responses.
+func splitBatch(resp *response, n int) ([]*response, error) {
+	resps := make([]*response, n)
+
+	// the whole batch failed, the error is sent to every caller
+	if resp.failed() {
+		for i := range resps {
+			resps[i] = &response{context: resp.context}
+		}
+
+		return resps, nil
+	}
+
+	var items []*batchResponse
+	err := json.Unmarshal(resp.body, &items)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "malformed batch response: %v", err)
+	}
+
+	if len(items) != n {
+		return nil, status.Errorf(codes.Internal, "worker returned %d responses for %d requests", len(items), n)
+	}
+
+	for i, item := range items {
+		if item == nil {
+			return nil, status.Errorf(codes.Internal, "empty response for the batch item %d", i)
+		}
+
+		resps[i] = &response{body: item.Body, context: item.Context}
+	}
+
+	return resps, nil
+}
@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/pool/v2/payload"
+	"github.com/roadrunner-server/pool/v2/pool/static_pool"
+	"github.com/roadrunner-server/pool/v2/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// recordingPool keeps the executed payloads and fails every call.
+type recordingPool struct {
+	mu   sync.Mutex
+	plds []*payload.Payload
+}
+
+func (r *recordingPool) Workers() []*worker.Process { return nil }
+func (r *recordingPool) Exec(_ context.Context, pld *payload.Payload, _ chan struct{}) (chan *static_pool.PExec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.plds = append(r.plds, &payload.Payload{Context: append([]byte(nil), pld.Context...), Body: append([]byte(nil), pld.Body...)})
+	return nil, errors.Str("no free workers")
+}
+func (r *recordingPool) Reset(context.Context) error { return nil }
+func (r *recordingPool) Destroy(context.Context)     {}
+
+func newBatchProxy(t *testing.T, cfg *BatchingConfig) (*Proxy, *recordingPool) {
+	t.Helper()
+	require.NoError(t, cfg.InitDefaults())
+
+	pool := &recordingPool{}
+	p := newTestProxy("app.Service")
+	p.grpcPool = pool
+	p.SetBatching(NewBatcher(cfg))
+
+	return p, pool
+}
+
+func TestBatchingConfigInitDefaults(t *testing.T) {
+	cfg := &BatchingConfig{Methods: []string{"/app.Service/Track"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, []string{"app.Service/Track"}, cfg.Methods)
+	assert.Equal(t, 10, cfg.MaxItems)
+	assert.Equal(t, time.Millisecond*5, cfg.MaxWait)
+
+	assert.Error(t, (&BatchingConfig{Methods: []string{"Track"}}).InitDefaults())
+	assert.Error(t, (&BatchingConfig{MaxItems: -1}).InitDefaults())
+}
+
+func TestBatchGroupsCalls(t *testing.T) {
+	p, pool := newBatchProxy(t, &BatchingConfig{
+		Methods:  []string{"app.Service/Track"},
+		MaxItems: 3,
+		MaxWait:  time.Minute,
+	})
+
+	require.True(t, p.isBatched("Track"))
+	require.False(t, p.isBatched("Get"))
+
+	var wg sync.WaitGroup
+	for i, body := range []string{"a", "b", "c"} {
+		wg.Go(func() {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("item", body))
+			// the calls are sent after the batch is full
+			time.Sleep(time.Millisecond * time.Duration(i))
+
+			in := codec.RawMessage(body)
+			_, err := p.call(ctx, "Track", &in)
+			assert.Error(t, err)
+		})
+	}
+	wg.Wait()
+
+	require.Len(t, pool.plds, 1)
+
+	bctx := &batchContext{}
+	require.NoError(t, json.Unmarshal(pool.plds[0].Context, bctx))
+	assert.Equal(t, "app.Service", bctx.Service)
+	assert.Equal(t, "Track", bctx.Method)
+	require.Len(t, bctx.Batch, 3)
+
+	var bodies [][]byte
+	require.NoError(t, json.Unmarshal(pool.plds[0].Body, &bodies))
+	require.Len(t, bodies, 3)
+
+	// every item keeps its own context
+	for i, item := range bctx.Batch {
+		assert.Equal(t, []string{string(bodies[i])}, item.Context["item"])
+	}
+}
+
+func TestBatchMaxWait(t *testing.T) {
+	p, pool := newBatchProxy(t, &BatchingConfig{
+		Methods: []string{"app.Service/Track"},
+		MaxWait: time.Millisecond * 10,
+	})
+
+	in := codec.RawMessage("a")
+	_, err := p.call(context.Background(), "Track", &in)
+	assert.Error(t, err)
+	require.Len(t, pool.plds, 1)
+
+	// the caller does not wait for the batch after its context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = p.call(ctx, "Track", &in)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestSplitBatch(t *testing.T) {
+	resps, err := splitBatch(&response{body: []byte(`[{"body":"YQ==","context":{"headers":"{}"}},{"body":"","context":{"error":"CAgSBGZhaWw="}}]`)}, 2)
+	require.NoError(t, err)
+	require.Len(t, resps, 2)
+	assert.Equal(t, []byte("a"), resps[0].body)
+	assert.False(t, resps[0].failed())
+	assert.True(t, resps[1].failed())
+
+	// the error of the whole batch is sent to every caller
+	resps, err = splitBatch(&response{context: []byte(`{"error":"CAgSBGZhaWw="}`)}, 2)
+	require.NoError(t, err)
+	require.Len(t, resps, 2)
+	assert.True(t, resps[0].failed())
+	assert.True(t, resps[1].failed())
+
+	_, err = splitBatch(&response{body: []byte(`[{"body":""}]`)}, 2)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	_, err = splitBatch(&response{body: []byte(`{}`)}, 1)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	_, err = splitBatch(&response{body: []byte(`[null]`)}, 1)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
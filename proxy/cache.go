@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+)
+
+const cacheControl string = "cache-control"
+
+// CacheConfig configures the in-memory LRU cache of the worker responses. A worker might disable caching of
+// a response with the `cache-control: no-store` response header or override the TTL with `cache-control: max-age=N`.
+type CacheConfig struct {
+	// Methods are the fully-qualified method names to cache, e.g. `package.Service/Method`.
+	Methods []string `mapstructure:"methods"`
+	// Metadata are the metadata keys which are part of the cache key. Defaults to `authorization`.
+	Metadata []string `mapstructure:"metadata"`
+	// TTL is the default time a response is cached. Defaults to 1m.
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxSize is the cache size in MB. Defaults to 64.
+	MaxSize int64 `mapstructure:"max_size"`
+}
+
+func (c *CacheConfig) InitDefaults() error {
+	const op = errors.Op("grpc_cache_init")
+
+	for i := range c.Methods {
+		var err error
+		c.Methods[i], err = methodname.Normalize(c.Methods[i])
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Metadata == nil {
+		c.Metadata = []string{"authorization"}
+	}
+
+	for i := range c.Metadata {
+		c.Metadata[i] = strings.ToLower(c.Metadata[i])
+	}
+
+	if c.TTL == 0 {
+		c.TTL = time.Minute
+	}
+
+	if c.MaxSize == 0 {
+		c.MaxSize = 64
+	}
+
+	if c.TTL < 0 || c.MaxSize < 0 {
+		return errors.E(op, errors.Str("ttl and max_size should not be negative"))
+	}
+
+	return nil
+}
+
+// ResponseCache is an LRU cache of the worker responses limited by the total size in bytes.
+type ResponseCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxSize  int64
+	size     int64
+	metadata []string
+	methods  map[string]struct{}
+	entries  map[string]*cacheEntry
+	// most recently used entries are at the front
+	lru *list.List
+
+	requests *prometheus.CounterVec
+}
+
+type cacheEntry struct {
+	key     string
+	resp    *response
+	expires time.Time
+	elem    *list.Element
+}
+
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.resp.body) + len(e.resp.context))
+}
+
+// NewResponseCache creates a cache for the configured methods. Hits and misses are counted by the requests
+// counter with the `grpc_method` and `result` labels.
+func NewResponseCache(cfg *CacheConfig, requests *prometheus.CounterVec) *ResponseCache {
+	methods := make(map[string]struct{}, len(cfg.Methods))
+	for _, m := range cfg.Methods {
+		methods[m] = struct{}{}
+	}
+
+	return &ResponseCache{
+		ttl:      cfg.TTL,
+		maxSize:  cfg.MaxSize * 1024 * 1024,
+		metadata: cfg.Metadata,
+		methods:  methods,
+		entries:  make(map[string]*cacheEntry),
+		lru:      list.New(),
+		requests: requests,
+	}
+}
+
+// SetCache assigns the response cache.
+func (p *Proxy) SetCache(c *ResponseCache) {
+	p.cache = c
+}
+
+func (p *Proxy) isCached(method string) bool {
+	if p.cache == nil {
+		return false
+	}
+
+	_, ok := p.cache.methods[p.name+"/"+method]
+	return ok
+}
+
+// fetch returns the cached response or executes fn and caches its result.
+func (c *ResponseCache) fetch(ctx context.Context, fullMethod string, body []byte, fn func() (*response, error)) (*response, error) {
+	key := requestKey(ctx, fullMethod, c.metadata, body)
+
+	if resp := c.get(key); resp != nil {
+		c.requests.WithLabelValues(fullMethod, "hit").Inc()
+		return resp, nil
+	}
+	c.requests.WithLabelValues(fullMethod, "miss").Inc()
+
+	resp, err := fn()
+	if err != nil || resp.failed() {
+		return resp, err
+	}
+
+	ttl, ok := responseTTL(resp.context, c.ttl)
+	if ok {
+		c.put(key, resp, ttl)
+	}
+
+	return resp, nil
+}
+
+func (c *ResponseCache) get(key string) *response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	if time.Now().After(e.expires) {
+		c.remove(e)
+		return nil
+	}
+
+	c.lru.MoveToFront(e.elem)
+	return e.resp
+}
+
+func (c *ResponseCache) put(key string, resp *response, ttl time.Duration) {
+	e := &cacheEntry{key: key, resp: resp, expires: time.Now().Add(ttl)}
+	if e.size() > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.entries[key]; ok {
+		c.remove(old)
+	}
+
+	e.elem = c.lru.PushFront(e)
+	c.entries[key] = e
+	c.size += e.size()
+
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back().Value.(*cacheEntry))
+	}
+}
+
+func (c *ResponseCache) remove(e *cacheEntry) {
+	c.lru.Remove(e.elem)
+	delete(c.entries, e.key)
+	c.size -= e.size()
+}
+
+// responseTTL reads the cache-control header of the worker response. It returns false when the response
+// should not be cached.
+func responseTTL(rctx []byte, ttl time.Duration) (time.Duration, bool) {
+	if len(rctx) == 0 {
+		return ttl, true
+	}
+
+	var rpcMetadata map[string]string
+	if err := json.Unmarshal(rctx, &rpcMetadata); err != nil {
+		return 0, false
+	}
+
+	var value string
+	// old API, headers are sent as is
+	for k, v := range rpcMetadata {
+		if strings.EqualFold(k, cacheControl) {
+			value = v
+		}
+	}
+
+	if h, ok := rpcMetadata[headers]; ok {
+		hdr := make(map[string]any)
+		if err := json.Unmarshal([]byte(h), &hdr); err != nil {
+			return 0, false
+		}
+
+		for k, v := range hdr {
+			if s, ok := v.(string); ok && strings.EqualFold(k, cacheControl) {
+				value = s
+			}
+		}
+	}
+
+	for directive := range strings.SplitSeq(value, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0, false
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return ttl, true
+}
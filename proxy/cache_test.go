@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCache(t *testing.T, cfg *CacheConfig) *ResponseCache {
+	t.Helper()
+	require.NoError(t, cfg.InitDefaults())
+	return NewResponseCache(cfg, prometheus.NewCounterVec(prometheus.CounterOpts{Name: "cache"}, []string{"grpc_method", "result"}))
+}
+
+func TestCacheConfigInitDefaults(t *testing.T) {
+	cfg := &CacheConfig{Methods: []string{"/app.Service/Get"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, []string{"app.Service/Get"}, cfg.Methods)
+	assert.Equal(t, []string{"authorization"}, cfg.Metadata)
+	assert.Equal(t, time.Minute, cfg.TTL)
+	assert.Equal(t, int64(64), cfg.MaxSize)
+
+	assert.Error(t, (&CacheConfig{Methods: []string{"Get"}}).InitDefaults())
+	assert.Error(t, (&CacheConfig{TTL: -time.Second}).InitDefaults())
+}
+
+func TestCacheFetch(t *testing.T) {
+	c := newTestCache(t, &CacheConfig{Methods: []string{"app.Service/Get"}})
+
+	var calls atomic.Int32
+	fn := func() (*response, error) {
+		calls.Add(1)
+		return &response{body: []byte("item")}, nil
+	}
+
+	for range 3 {
+		resp, err := c.fetch(context.Background(), "app.Service/Get", []byte("req"), fn)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("item"), resp.body)
+	}
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.requests.WithLabelValues("app.Service/Get", "hit")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.requests.WithLabelValues("app.Service/Get", "miss")))
+
+	// another request body is another entry
+	_, err := c.fetch(context.Background(), "app.Service/Get", []byte("other"), fn)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCacheSkipsFailedAndOptedOutResponses(t *testing.T) {
+	c := newTestCache(t, &CacheConfig{Methods: []string{"app.Service/Get"}})
+
+	for _, rctx := range []string{
+		`{"error":"CAgSBGZhaWw="}`,
+		`{"Cache-Control":"no-store"}`,
+		`{"headers":"{\"cache-control\":\"no-cache\"}"}`,
+	} {
+		var calls atomic.Int32
+		fn := func() (*response, error) {
+			calls.Add(1)
+			return &response{context: []byte(rctx)}, nil
+		}
+
+		for range 2 {
+			_, err := c.fetch(context.Background(), "app.Service/Get", []byte("req"), fn)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), calls.Load(), rctx)
+	}
+}
+
+func TestCacheExpiration(t *testing.T) {
+	c := newTestCache(t, &CacheConfig{Methods: []string{"app.Service/Get"}, TTL: time.Millisecond * 20})
+
+	var calls atomic.Int32
+	fn := func() (*response, error) {
+		calls.Add(1)
+		return &response{}, nil
+	}
+
+	_, _ = c.fetch(context.Background(), "app.Service/Get", nil, fn)
+	time.Sleep(time.Millisecond * 30)
+	_, _ = c.fetch(context.Background(), "app.Service/Get", nil, fn)
+
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTestCache(t, &CacheConfig{Methods: []string{"app.Service/Get"}})
+	c.maxSize = 70
+
+	big := make([]byte, 30)
+	c.put("a", &response{body: big}, time.Minute)
+	c.put("b", &response{body: big}, time.Minute)
+	// touch a, so b becomes the least recently used
+	require.NotNil(t, c.get("a"))
+	c.put("c", &response{body: big}, time.Minute)
+
+	assert.NotNil(t, c.get("a"))
+	assert.Nil(t, c.get("b"))
+	assert.NotNil(t, c.get("c"))
+	assert.LessOrEqual(t, c.size, c.maxSize)
+
+	// entries larger than the cache are not stored
+	c.put("d", &response{body: make([]byte, 200)}, time.Minute)
+	assert.Nil(t, c.get("d"))
+}
+
+func TestResponseTTL(t *testing.T) {
+	ttl, ok := responseTTL(nil, time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, ttl)
+
+	ttl, ok = responseTTL([]byte(`{"headers":"{\"cache-control\":\"public, max-age=300\"}"}`), time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute*5, ttl)
+
+	ttl, ok = responseTTL([]byte(`{"cache-control":"max-age=10"}`), time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second*10, ttl)
+
+	_, ok = responseTTL([]byte(`{"cache-control":"max-age=0"}`), time.Minute)
+	assert.False(t, ok)
+}
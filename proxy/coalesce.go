@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strings"
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// CoalescingConfig configures the coalescing of identical in-flight calls into a single worker execution.
+// Methods marked with the NO_SIDE_EFFECTS idempotency level are coalesced when the section is present.
+type CoalescingConfig struct {
+	// Methods are the additional fully-qualified method names to coalesce, e.g. `package.Service/Method`.
+	Methods []string `mapstructure:"methods"`
+	// Metadata are the metadata keys which are part of the call identity. Defaults to `authorization`,
+	// calls with different values of these keys are never coalesced.
+	Metadata []string `mapstructure:"metadata"`
+}
+
+func (c *CoalescingConfig) InitDefaults() error {
+	const op = errors.Op("grpc_coalescing_init")
+
+	for i := range c.Methods {
+		var err error
+		c.Methods[i], err = methodname.Normalize(c.Methods[i])
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Metadata == nil {
+		c.Metadata = []string{"authorization"}
+	}
+
+	for i := range c.Metadata {
+		c.Metadata[i] = strings.ToLower(c.Metadata[i])
+	}
+
+	return nil
+}
+
+// Coalescer shares the result of a worker execution between the concurrent calls with the same method,
+// request body and selected metadata.
+type Coalescer struct {
+	mu       sync.Mutex
+	metadata []string
+	flights  map[string]*flight
+}
+
+type flight struct {
+	// ctx of the call which executes the request
+	ctx  context.Context
+	done chan struct{}
+	resp *response
+	err  error
+}
+
+// NewCoalescer creates a coalescer keyed by the configured metadata.
+func NewCoalescer(cfg *CoalescingConfig) *Coalescer {
+	return &Coalescer{
+		metadata: cfg.Metadata,
+		flights:  make(map[string]*flight),
+	}
+}
+
+// SetCoalescing assigns the coalescer used for the given methods of the proxied service.
+func (p *Proxy) SetCoalescing(c *Coalescer, methods []string) {
+	if c == nil || len(methods) == 0 {
+		return
+	}
+
+	p.coalescer = c
+	p.coalesced = make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		p.coalesced[m] = struct{}{}
+	}
+}
+
+func (p *Proxy) isCoalesced(method string) bool {
+	_, ok := p.coalesced[method]
+	return ok
+}
+
+// do executes fn once for all the concurrent calls with the same key.
+func (c *Coalescer) do(ctx context.Context, key string, fn func() (*response, error)) (*response, error) {
+	c.mu.Lock()
+	if f, ok := c.flights[key]; ok {
+		c.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+
+		// the executing call was canceled by its client, this one is still alive, so run it on its own
+		if f.err != nil && f.ctx.Err() != nil && ctx.Err() == nil {
+			return fn()
+		}
+
+		return f.resp, f.err
+	}
+
+	f := &flight{ctx: ctx, done: make(chan struct{})}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	f.resp, f.err = fn()
+
+	c.mu.Lock()
+	delete(c.flights, key)
+	c.mu.Unlock()
+	close(f.done)
+
+	return f.resp, f.err
+}
+
+// requestKey identifies a call by its method, body and the values of the given metadata keys.
+func requestKey(ctx context.Context, fullMethod string, keys []string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(fullMethod))
+	writeCaller(h, ctx, keys)
+	h.Write([]byte{0})
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeCaller writes the caller identity to h: the values of the metadata keys and the verified client certificate.
+func writeCaller(h hash.Hash, ctx context.Context, keys []string) { //nolint:revive
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, k := range keys {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+		for _, v := range md.Get(k) {
+			h.Write([]byte{0})
+			h.Write([]byte(v))
+		}
+	}
+	// the workers might authorize the calls by the client certificate, the responses are not shared between the callers
+	if cert := peerCertificate(ctx); cert != nil {
+		h.Write([]byte{0})
+		h.Write(cert.Raw)
+	}
+}
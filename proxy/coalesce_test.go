@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCoalescingConfigInitDefaults(t *testing.T) {
+	cfg := &CoalescingConfig{Methods: []string{"/app.Service/Get"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, []string{"app.Service/Get"}, cfg.Methods)
+	assert.Equal(t, []string{"authorization"}, cfg.Metadata)
+
+	cfg = &CoalescingConfig{Metadata: []string{}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Empty(t, cfg.Metadata)
+
+	assert.Error(t, (&CoalescingConfig{Methods: []string{"Get"}}).InitDefaults())
+}
+
+func TestRequestKey(t *testing.T) {
+	keys := []string{"authorization"}
+	alice := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "alice", "x-request-id", "1"))
+	alice2 := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "alice", "x-request-id", "2"))
+	bob := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bob"))
+
+	assert.Equal(t, requestKey(alice, "app.Service/Get", keys, []byte("req")), requestKey(alice2, "app.Service/Get", keys, []byte("req")))
+	assert.NotEqual(t, requestKey(alice, "app.Service/Get", keys, []byte("req")), requestKey(bob, "app.Service/Get", keys, []byte("req")))
+	assert.NotEqual(t, requestKey(alice, "app.Service/Get", keys, []byte("req")), requestKey(alice, "app.Service/List", keys, []byte("req")))
+	assert.NotEqual(t, requestKey(alice, "app.Service/Get", keys, []byte("req")), requestKey(alice, "app.Service/Get", keys, []byte("other")))
+}
+
+func TestCoalescerSharesExecution(t *testing.T) {
+	c := NewCoalescer(&CoalescingConfig{})
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (*response, error) {
+		calls.Add(1)
+		<-release
+		return &response{body: []byte("item")}, nil
+	}
+
+	wg := &sync.WaitGroup{}
+	for range 10 {
+		wg.Go(func() {
+			resp, err := c.do(context.Background(), "key", fn)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("item"), resp.body)
+		})
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+
+	// finished flights are not reused
+	_, err := c.do(context.Background(), "key", fn)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCoalescerCanceledLeader(t *testing.T) {
+	c := NewCoalescer(&CoalescingConfig{})
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+
+	go func() {
+		_, _ = c.do(leaderCtx, "key", func() (*response, error) {
+			close(started)
+			<-leaderCtx.Done()
+			return nil, status.FromContextError(leaderCtx.Err()).Err()
+		})
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := c.do(context.Background(), "key", func() (*response, error) {
+			return &response{body: []byte("own")}, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("own"), resp.body)
+	}()
+
+	time.Sleep(time.Millisecond * 20)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("follower was not released")
+	}
+}
+
+func TestCoalescerFollowerDeadline(t *testing.T) {
+	c := NewCoalescer(&CoalescingConfig{})
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+
+	go func() {
+		_, _ = c.do(context.Background(), "key", func() (*response, error) {
+			close(started)
+			<-release
+			return &response{}, nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	_, err := c.do(ctx, "key", func() (*response, error) { return &response{}, nil })
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
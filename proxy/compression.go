@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/roadrunner-server/grpc/v6/compression"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// SetCompression assigns the response compression rules which belong to the proxied service.
+func (p *Proxy) SetCompression(rules []*compression.Rule) {
+	for _, r := range rules {
+		service, method, _ := strings.Cut(r.Method, "/")
+		if service != p.name {
+			continue
+		}
+
+		if p.compression == nil {
+			p.compression = make(map[string]*compression.Rule)
+		}
+
+		p.compression[method] = r
+	}
+}
+
+// setCompressor selects the compressor of the response by the method rule. The response is sent with the
+// compressor of the request when the client does not accept the configured one.
+func (p *Proxy) setCompressor(ctx context.Context, method string, size int) {
+	r, ok := p.compression[method]
+	if !ok {
+		return
+	}
+
+	name := r.Compressor
+	if size < r.MinSize {
+		name = encoding.Identity
+	}
+
+	if name != encoding.Identity {
+		// the in-process calls have no stream
+		accepted, err := grpc.ClientSupportedCompressors(ctx)
+		if err != nil || !slices.Contains(accepted, name) {
+			return
+		}
+	}
+
+	_ = grpc.SetSendCompressor(ctx, name)
+}
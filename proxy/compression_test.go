@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/roadrunner-server/grpc/v6/compression"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+// compressionRecorder records the compressor of the responses.
+type compressionRecorder struct {
+	mu          sync.Mutex
+	compression string
+}
+
+func (r *compressionRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func (r *compressionRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.InHeader); ok {
+		r.mu.Lock()
+		r.compression = h.Compression
+		r.mu.Unlock()
+	}
+}
+
+func (r *compressionRecorder) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.compression
+}
+
+func TestSetCompressor(t *testing.T) {
+	cfg := &compression.Config{
+		Compressors: []string{compression.ZstdName},
+		Rules: []*compression.Rule{
+			{Method: "grpc.health.v1.Health/Check", Compressor: compression.ZstdName, MinSize: 10},
+			{Method: "app.Other/Check", Compressor: "identity"},
+		},
+	}
+	require.NoError(t, cfg.InitDefaults())
+	require.NoError(t, compression.Register(cfg))
+
+	p := newTestProxy("grpc.health.v1.Health")
+	p.SetCompression(cfg.Rules)
+	require.Len(t, p.compression, 1)
+
+	// the response size is taken from the metadata, as the worker would return it
+	sized := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get("x-size"); len(v) > 0 {
+			size, _ := strconv.Atoi(v[0])
+			p.setCompressor(ctx, "Check", size)
+		}
+		return handler(ctx, req)
+	}
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(sized))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+
+	rec := &compressionRecorder{}
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithStatsHandler(rec))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	check := func(size string, opts ...grpc.CallOption) string {
+		ctx := context.Background()
+		if size != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-size", size)
+		}
+		_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, opts...)
+		require.NoError(t, err)
+		return rec.last()
+	}
+
+	// compressed with the rule compressor regardless of the request compression
+	assert.Equal(t, compression.ZstdName, check("100"))
+	assert.Equal(t, compression.ZstdName, check("100", grpc.UseCompressor(gzip.Name)))
+
+	// the small responses are sent uncompressed
+	assert.NotEqual(t, gzip.Name, check("1", grpc.UseCompressor(gzip.Name)))
+
+	// without a rule the response is compressed like the request
+	assert.Equal(t, gzip.Name, check("", grpc.UseCompressor(gzip.Name)))
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"context"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// FaultRule injects a synthetic delay and/or abort into the calls of a single method before
+// they reach a worker. It is intended for testing client retry and timeout behavior.
+type FaultRule struct {
+	// Method is the fully-qualified method name, e.g. `package.Service/Method`.
+	Method string `mapstructure:"method"`
+	// Header limits the rule to the calls carrying this metadata key (any value).
+	Header string `mapstructure:"header"`
+	// Delay is the time to wait before the worker call.
+	Delay time.Duration `mapstructure:"delay"`
+	// DelayPercentage is the share of the matching calls (0-100) which are delayed.
+	DelayPercentage float64 `mapstructure:"delay_percentage"`
+	// AbortCode is the gRPC status code returned for the aborted calls.
+	AbortCode uint32 `mapstructure:"abort_code"`
+	// AbortPercentage is the share of the matching calls (0-100) which are aborted.
+	AbortPercentage float64 `mapstructure:"abort_percentage"`
+}
+
+func (r *FaultRule) InitDefaults() error {
+	const op = errors.Op("grpc_fault_rule_init")
+
+	var err error
+	r.Method, err = methodname.Normalize(r.Method)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if r.Delay < 0 {
+		return errors.E(op, errors.Errorf("delay should not be negative, method: %s", r.Method))
+	}
+
+	if r.AbortCode > uint32(codes.Unauthenticated) {
+		return errors.E(op, errors.Errorf("unknown abort code %d, method: %s", r.AbortCode, r.Method))
+	}
+
+	// a delay or abort without a percentage applies to every matching call
+	if r.Delay > 0 && r.DelayPercentage == 0 {
+		r.DelayPercentage = 100
+	}
+
+	if r.AbortCode != 0 && r.AbortPercentage == 0 {
+		r.AbortPercentage = 100
+	}
+
+	if r.DelayPercentage < 0 || r.DelayPercentage > 100 || r.AbortPercentage < 0 || r.AbortPercentage > 100 {
+		return errors.E(op, errors.Errorf("percentage should be in the 0-100 range, method: %s", r.Method))
+	}
+
+	r.Header = strings.ToLower(r.Header)
+
+	return nil
+}
+
+// SetFaultInjection assigns the fault rules which belong to the proxied service.
+func (p *Proxy) SetFaultInjection(rules []*FaultRule) {
+	for _, r := range rules {
+		service, method, _ := strings.Cut(r.Method, "/")
+		if service != p.name {
+			continue
+		}
+
+		if p.faults == nil {
+			p.faults = make(map[string][]*FaultRule)
+		}
+
+		p.faults[method] = append(p.faults[method], r)
+	}
+}
+
+// injectFault applies the first fault rule matching the call. It returns an error when the call is aborted
+// or its context is done while delayed.
+func (p *Proxy) injectFault(ctx context.Context, method string) error {
+	rules := p.faults[method]
+	if len(rules) == 0 {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, r := range rules {
+		if r.Header != "" && len(md.Get(r.Header)) == 0 {
+			continue
+		}
+
+		if r.Delay > 0 && hit(r.DelayPercentage) {
+			p.log.Debug("injecting delay", "method", method, "delay", r.Delay)
+
+			timer := time.NewTimer(r.Delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		}
+
+		if r.AbortCode != 0 && hit(r.AbortPercentage) {
+			p.log.Debug("injecting abort", "method", method, "code", codes.Code(r.AbortCode).String())
+			return status.Error(codes.Code(r.AbortCode), "fault injected")
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// hit reports whether a call falls into the given percentage.
+func hit(percentage float64) bool {
+	return rand.Float64()*100 < percentage //nolint:gosec
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestProxy(name string) *Proxy {
+	return NewProxy(name, "", slog.New(slog.DiscardHandler), nil, &sync.RWMutex{}, propagation.TraceContext{})
+}
+
+func TestFaultRuleInitDefaults(t *testing.T) {
+	r := &FaultRule{Method: "/app.Service/Create", Delay: time.Second, AbortCode: uint32(codes.Unavailable)}
+	require.NoError(t, r.InitDefaults())
+	assert.Equal(t, "app.Service/Create", r.Method)
+	assert.Equal(t, float64(100), r.DelayPercentage)
+	assert.Equal(t, float64(100), r.AbortPercentage)
+
+	assert.Error(t, (&FaultRule{Method: "Create"}).InitDefaults())
+	assert.Error(t, (&FaultRule{Method: "app.Service/Create", AbortCode: 42}).InitDefaults())
+	assert.Error(t, (&FaultRule{Method: "app.Service/Create", AbortCode: 1, AbortPercentage: 120}).InitDefaults())
+	assert.Error(t, (&FaultRule{Method: "app.Service/Create", Delay: -time.Second}).InitDefaults())
+}
+
+func TestInjectFaultAbort(t *testing.T) {
+	p := newTestProxy("app.Service")
+
+	rules := []*FaultRule{
+		{Method: "app.Service/Create", AbortCode: uint32(codes.Unavailable)},
+		{Method: "app.Other/Create", AbortCode: uint32(codes.Internal)},
+	}
+	for _, r := range rules {
+		require.NoError(t, r.InitDefaults())
+	}
+	p.SetFaultInjection(rules)
+
+	err := p.injectFault(context.Background(), "Create")
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// methods without rules are untouched
+	assert.NoError(t, p.injectFault(context.Background(), "Get"))
+}
+
+func TestInjectFaultHeader(t *testing.T) {
+	p := newTestProxy("app.Service")
+
+	rule := &FaultRule{Method: "app.Service/Create", Header: "X-Fault", AbortCode: uint32(codes.Aborted)}
+	require.NoError(t, rule.InitDefaults())
+	p.SetFaultInjection([]*FaultRule{rule})
+
+	assert.NoError(t, p.injectFault(context.Background(), "Create"))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-fault", "1"))
+	err := p.injectFault(ctx, "Create")
+	require.Error(t, err)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestInjectFaultDelay(t *testing.T) {
+	p := newTestProxy("app.Service")
+
+	rule := &FaultRule{Method: "app.Service/Create", Delay: time.Millisecond * 50}
+	require.NoError(t, rule.InitDefaults())
+	p.SetFaultInjection([]*FaultRule{rule})
+
+	start := time.Now()
+	require.NoError(t, p.injectFault(context.Background(), "Create"))
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*50)
+
+	// the delay is interrupted by the caller's deadline
+	rule.Delay = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	err := p.injectFault(ctx, "Create")
+	require.Error(t, err)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+)
+
+// HedgingConfig configures the request hedging. When a worker has not answered within the configured percentile of
+// the method latency, the same request is sent to a second worker and the first response wins.
+// Methods marked with the NO_SIDE_EFFECTS idempotency level are hedged when the section is present.
+type HedgingConfig struct {
+	// Methods are the additional fully-qualified method names to hedge, e.g. `package.Service/Method`.
+	Methods []string `mapstructure:"methods"`
+	// Percentile of the observed method latency after which the hedged request is sent. Defaults to 95.
+	Percentile float64 `mapstructure:"percentile"`
+	// Delay is used until enough latencies are observed. Defaults to 100ms.
+	Delay time.Duration `mapstructure:"delay"`
+	// MinDelay is the lower bound of the computed delay, it prevents hedging every call of the fast methods. Defaults to 5ms.
+	MinDelay time.Duration `mapstructure:"min_delay"`
+	// Window is the number of the last latencies the percentile is computed from. Defaults to 1000.
+	Window int `mapstructure:"window"`
+}
+
+func (c *HedgingConfig) InitDefaults() error {
+	const op = errors.Op("grpc_hedging_init")
+
+	for i := range c.Methods {
+		var err error
+		c.Methods[i], err = methodname.Normalize(c.Methods[i])
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Percentile == 0 {
+		c.Percentile = 95
+	}
+
+	if c.Delay == 0 {
+		c.Delay = time.Millisecond * 100
+	}
+
+	if c.MinDelay == 0 {
+		c.MinDelay = time.Millisecond * 5
+	}
+
+	if c.Window == 0 {
+		c.Window = 1000
+	}
+
+	if c.Percentile < 0 || c.Percentile > 100 {
+		return errors.E(op, errors.Errorf("percentile should be in the [0, 100] range, provided: %v", c.Percentile))
+	}
+
+	if c.Delay < 0 || c.MinDelay < 0 || c.Window < 0 {
+		return errors.E(op, errors.Str("delay, min_delay and window should not be negative"))
+	}
+
+	return nil
+}
+
+// Hedger keeps the latencies of the hedged methods.
+type Hedger struct {
+	cfg *HedgingConfig
+
+	mu        sync.Mutex
+	latencies map[string]*latencies
+}
+
+// latencies is a ring buffer of the observed latencies with the cached percentile.
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	// recorded since the last percentile computation
+	recorded int
+	delay    time.Duration
+}
+
+// NewHedger creates a hedger computing the delays from the configured percentile.
+func NewHedger(cfg *HedgingConfig) *Hedger {
+	return &Hedger{
+		cfg:       cfg,
+		latencies: make(map[string]*latencies),
+	}
+}
+
+// SetHedging assigns the hedger used for the given methods of the proxied service.
+func (p *Proxy) SetHedging(h *Hedger, methods []string) {
+	if h == nil || len(methods) == 0 {
+		return
+	}
+
+	p.hedger = h
+	p.hedged = make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		p.hedged[m] = struct{}{}
+	}
+}
+
+func (p *Proxy) isHedged(method string) bool {
+	_, ok := p.hedged[method]
+	return ok
+}
+
+type hedgeResult struct {
+	resp *response
+	err  error
+}
+
+// hedge executes the call and sends it to a second worker when the first one is slower than the method delay.
+// The first successful response is returned and the slower execution is canceled, the response carrying the worker
+// error waits for the other execution. The recorded latency is the time since the first execution started, so the
+// slow workers keep the delay up whether they won or lost.
+func (p *Proxy) hedge(ctx context.Context, method string, exec func(ctx context.Context) (*response, error)) (*response, error) {
+	lat := p.hedger.get(p.name + "/" + method)
+
+	ctx, cancel := context.WithCancel(ctx)
+	// cancels the slower execution
+	defer cancel()
+
+	results := make(chan *hedgeResult, 2)
+	run := func() {
+		resp, err := exec(ctx)
+		results <- &hedgeResult{resp: resp, err: err}
+	}
+
+	start := time.Now()
+	go run()
+
+	timer := time.NewTimer(lat.percentile(p.hedger.cfg))
+	defer timer.Stop()
+
+	running := 1
+	var last *hedgeResult
+	for running > 0 {
+		select {
+		case <-timer.C:
+			p.log.Debug("hedging the call", "method", method)
+			running++
+			go run()
+		case res := <-results:
+			running--
+			if res.err == nil && !res.resp.failed() {
+				lat.record(time.Since(start), p.hedger.cfg.Window)
+				return res.resp, nil
+			}
+			last = res
+		}
+	}
+
+	return last.resp, last.err
+}
+
+func (h *Hedger) get(method string) *latencies {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	l, ok := h.latencies[method]
+	if !ok {
+		l = &latencies{}
+		h.latencies[method] = l
+	}
+
+	return l
+}
+
+func (l *latencies) record(d time.Duration, window int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < window {
+		l.samples = append(l.samples, d)
+	} else {
+		l.samples[l.next] = d
+		l.next = (l.next + 1) % window
+	}
+
+	l.recorded++
+}
+
+// percentile returns the hedging delay. It is recomputed after every tenth of the window is recorded,
+// the configured delay is used until the window is filled to a tenth.
+func (l *latencies) percentile(cfg *HedgingConfig) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	step := max(cfg.Window/10, 1)
+	if len(l.samples) < step {
+		return cfg.Delay
+	}
+
+	if l.delay == 0 || l.recorded >= step {
+		sorted := slices.Clone(l.samples)
+		slices.Sort(sorted)
+
+		i := int(math.Ceil(cfg.Percentile/100*float64(len(sorted)))) - 1
+		l.delay = max(sorted[max(i, 0)], cfg.MinDelay)
+		l.recorded = 0
+	}
+
+	return l.delay
+}
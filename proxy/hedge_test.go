@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHedgeProxy(t *testing.T, cfg *HedgingConfig, pool Pool) *Proxy {
+	t.Helper()
+	require.NoError(t, cfg.InitDefaults())
+
+	p := newTestProxy("app.Service")
+	p.grpcPool = pool
+	p.SetHedging(NewHedger(cfg), []string{"Get"})
+
+	return p
+}
+
+func TestHedgingConfigInitDefaults(t *testing.T) {
+	cfg := &HedgingConfig{Methods: []string{"/app.Service/Get"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, []string{"app.Service/Get"}, cfg.Methods)
+	assert.Equal(t, float64(95), cfg.Percentile)
+	assert.Equal(t, time.Millisecond*100, cfg.Delay)
+	assert.Equal(t, 1000, cfg.Window)
+
+	assert.Error(t, (&HedgingConfig{Methods: []string{"Get"}}).InitDefaults())
+	assert.Error(t, (&HedgingConfig{Percentile: 101}).InitDefaults())
+	assert.Error(t, (&HedgingConfig{Window: -1}).InitDefaults())
+}
+
+func TestHedgeSendsSecondRequest(t *testing.T) {
+	pool := &failingPool{release: make(chan struct{})}
+	p := newHedgeProxy(t, &HedgingConfig{Delay: time.Millisecond * 10}, pool)
+
+	require.True(t, p.isHedged("Get"))
+	require.False(t, p.isHedged("Update"))
+
+	go func() {
+		// the first worker is slow, so the call is hedged
+		assert.Eventually(t, func() bool { return pool.calls.Load() == 2 }, time.Second, time.Millisecond)
+		close(pool.release)
+	}()
+
+	in := codec.RawMessage("req")
+	_, err := p.call(context.Background(), "Get", &in)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), pool.calls.Load())
+}
+
+func TestHedgeFastFailure(t *testing.T) {
+	pool := &failingPool{}
+	p := newHedgeProxy(t, &HedgingConfig{Delay: time.Millisecond * 50}, pool)
+
+	in := codec.RawMessage("req")
+	_, err := p.call(context.Background(), "Get", &in)
+	assert.Error(t, err)
+
+	// the failed call is not hedged
+	time.Sleep(time.Millisecond * 60)
+	assert.Equal(t, int32(1), pool.calls.Load())
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	cfg := &HedgingConfig{Window: 100, Percentile: 90, Delay: time.Second, MinDelay: time.Millisecond}
+	require.NoError(t, cfg.InitDefaults())
+
+	l := &latencies{}
+	assert.Equal(t, time.Second, l.percentile(cfg))
+
+	for i := 1; i <= 100; i++ {
+		l.record(time.Duration(i)*time.Millisecond, cfg.Window)
+	}
+	assert.Equal(t, time.Millisecond*90, l.percentile(cfg))
+
+	// the ring buffer keeps only the last window
+	for range 100 {
+		l.record(time.Millisecond*500, cfg.Window)
+	}
+	assert.Len(t, l.samples, 100)
+	assert.Equal(t, time.Millisecond*500, l.percentile(cfg))
+
+	// the computed delay is not lower than min_delay
+	for range 100 {
+		l.record(time.Microsecond, cfg.Window)
+	}
+	assert.Equal(t, time.Millisecond, l.percentile(cfg))
+}
+
+func TestHedgeWorkerError(t *testing.T) {
+	p := newHedgeProxy(t, &HedgingConfig{Delay: time.Millisecond * 10}, &failingPool{})
+
+	var calls atomic.Int32
+	resp, err := p.hedge(context.Background(), "Get", func(ctx context.Context) (*response, error) {
+		if calls.Add(1) == 1 {
+			// the first worker answers with an error after the call is hedged
+			time.Sleep(time.Millisecond * 20)
+			return &response{context: []byte(`{"error":"boom"}`)}, nil
+		}
+
+		time.Sleep(time.Millisecond * 40)
+		return &response{body: []byte("ok")}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(resp.body))
+	assert.Equal(t, int32(2), calls.Load())
+
+	// both workers failed, the error is returned to the client
+	resp, err = p.hedge(context.Background(), "Get", func(context.Context) (*response, error) {
+		return &response{context: []byte(`{"error":"boom"}`)}, nil
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.failed())
+}
+
+func TestHedgeRecordsPrimaryLatency(t *testing.T) {
+	p := newHedgeProxy(t, &HedgingConfig{Delay: time.Millisecond * 10}, &failingPool{})
+
+	var calls atomic.Int32
+	_, err := p.hedge(context.Background(), "Get", func(ctx context.Context) (*response, error) {
+		if calls.Add(1) == 1 {
+			// the primary worker is slow and loses
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		time.Sleep(time.Millisecond * 20)
+		return &response{body: []byte("ok")}, nil
+	})
+	require.NoError(t, err)
+
+	// the time since the primary call started, not the duration of the hedged one
+	l := p.hedger.get("app.Service/Get")
+	require.Len(t, l.samples, 1)
+	assert.GreaterOrEqual(t, l.samples[0], time.Millisecond*30)
+}
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const defaultIdempotencyHeader string = "idempotency-key"
+
+// IdempotencyConfig configures the deduplication of the retried mutating calls. The calls to the listed methods
+// carrying the same idempotency key are executed once, repeated calls get the stored response.
+type IdempotencyConfig struct {
+	// Methods are the fully-qualified method names, e.g. `package.Service/Method`.
+	Methods []string `mapstructure:"methods"`
+	// Header is the metadata key holding the idempotency key. Defaults to `idempotency-key`.
+	Header string `mapstructure:"header"`
+	// Metadata are the metadata keys identifying the caller, the stored responses are never replayed to another caller.
+	// Defaults to `authorization`.
+	Metadata []string `mapstructure:"metadata"`
+	// TTL is the time a response is kept after the first call. Defaults to 1h.
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxEntries limits the number of stored responses. Defaults to 10000.
+	MaxEntries int `mapstructure:"max_entries"`
+}
+
+func (c *IdempotencyConfig) InitDefaults() error {
+	const op = errors.Op("grpc_idempotency_init")
+
+	for i := range c.Methods {
+		var err error
+		c.Methods[i], err = methodname.Normalize(c.Methods[i])
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if c.Header == "" {
+		c.Header = defaultIdempotencyHeader
+	}
+	c.Header = strings.ToLower(c.Header)
+
+	if c.Metadata == nil {
+		c.Metadata = []string{"authorization"}
+	}
+
+	for i := range c.Metadata {
+		c.Metadata[i] = strings.ToLower(c.Metadata[i])
+	}
+
+	if c.TTL == 0 {
+		c.TTL = time.Hour
+	}
+
+	if c.MaxEntries == 0 {
+		c.MaxEntries = 10000
+	}
+
+	if c.TTL < 0 || c.MaxEntries < 0 {
+		return errors.E(op, errors.Str("ttl and max_entries should not be negative"))
+	}
+
+	return nil
+}
+
+// IdempotencyStore keeps the worker responses in memory keyed by the method, the caller and the idempotency key.
+type IdempotencyStore struct {
+	mu       sync.Mutex
+	header   string
+	metadata []string
+	ttl      time.Duration
+	max      int
+	methods  map[string]struct{}
+	entries  map[string]*idempotencyEntry
+	// insertion order, the oldest entries expire first
+	order *list.List
+}
+
+type idempotencyEntry struct {
+	key     string
+	hash    [sha256.Size]byte
+	expires time.Time
+	elem    *list.Element
+
+	// closed when the first call is finished
+	done chan struct{}
+	resp *response
+	err  error
+}
+
+// NewIdempotencyStore creates a store for the configured methods.
+func NewIdempotencyStore(cfg *IdempotencyConfig) *IdempotencyStore {
+	methods := make(map[string]struct{}, len(cfg.Methods))
+	for _, m := range cfg.Methods {
+		methods[m] = struct{}{}
+	}
+
+	return &IdempotencyStore{
+		header:   cfg.Header,
+		metadata: cfg.Metadata,
+		ttl:      cfg.TTL,
+		max:      cfg.MaxEntries,
+		methods:  methods,
+		entries:  make(map[string]*idempotencyEntry),
+		order:    list.New(),
+	}
+}
+
+// SetIdempotency assigns the store used to deduplicate the calls to the configured methods.
+func (p *Proxy) SetIdempotency(store *IdempotencyStore) {
+	p.idempotency = store
+}
+
+// idempotencyKey returns the store key for the call or an empty string when the call should not be deduplicated.
+func (p *Proxy) idempotencyKey(ctx context.Context, method string) string {
+	if p.idempotency == nil {
+		return ""
+	}
+
+	fullMethod := p.name + "/" + method
+	if _, ok := p.idempotency.methods[fullMethod]; !ok {
+		return ""
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(p.idempotency.header)
+	if len(keys) == 0 || keys[0] == "" {
+		return ""
+	}
+
+	// the same key sent by another caller is another call
+	h := sha256.New()
+	writeCaller(h, ctx, p.idempotency.metadata)
+
+	return fullMethod + "\x00" + keys[0] + "\x00" + hex.EncodeToString(h.Sum(nil))
+}
+
+// do executes fn once per key. A concurrent call with the same key waits for the first one to finish,
+// a later call gets the stored response. Failed calls are not stored, so they might be retried.
+func (s *IdempotencyStore) do(ctx context.Context, key string, body []byte, fn func() (*response, error)) (*response, error) {
+	hash := sha256.Sum256(body)
+
+	s.mu.Lock()
+	s.evictExpired(time.Now())
+
+	if e, ok := s.entries[key]; ok {
+		s.mu.Unlock()
+
+		if e.hash != hash {
+			return nil, status.Error(codes.InvalidArgument, "idempotency key was already used with a different request")
+		}
+
+		select {
+		case <-e.done:
+			return e.resp, e.err
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	e := &idempotencyEntry{
+		key:     key,
+		hash:    hash,
+		expires: time.Now().Add(s.ttl),
+		done:    make(chan struct{}),
+	}
+	e.elem = s.order.PushBack(e)
+	s.entries[key] = e
+
+	for s.order.Len() > s.max {
+		s.remove(s.order.Front().Value.(*idempotencyEntry))
+	}
+	s.mu.Unlock()
+
+	e.resp, e.err = fn()
+
+	if e.err != nil || e.resp.failed() {
+		s.mu.Lock()
+		if s.entries[key] == e {
+			s.remove(e)
+		}
+		s.mu.Unlock()
+	}
+
+	close(e.done)
+
+	return e.resp, e.err
+}
+
+func (s *IdempotencyStore) evictExpired(now time.Time) {
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		e := el.Value.(*idempotencyEntry)
+		if now.Before(e.expires) {
+			return
+		}
+
+		s.remove(e)
+	}
+}
+
+func (s *IdempotencyStore) remove(e *idempotencyEntry) {
+	s.order.Remove(e.elem)
+	delete(s.entries, e.key)
+}
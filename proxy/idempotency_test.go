@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestIdempotencyStore(t *testing.T, cfg *IdempotencyConfig) *IdempotencyStore {
+	t.Helper()
+	require.NoError(t, cfg.InitDefaults())
+	return NewIdempotencyStore(cfg)
+}
+
+func TestIdempotencyConfigInitDefaults(t *testing.T) {
+	cfg := &IdempotencyConfig{Methods: []string{"/app.Service/Create"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, []string{"app.Service/Create"}, cfg.Methods)
+	assert.Equal(t, "idempotency-key", cfg.Header)
+	assert.Equal(t, []string{"authorization"}, cfg.Metadata)
+	assert.Equal(t, time.Hour, cfg.TTL)
+	assert.Equal(t, 10000, cfg.MaxEntries)
+
+	assert.Error(t, (&IdempotencyConfig{Methods: []string{"Create"}}).InitDefaults())
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	p := newTestProxy("app.Service")
+	p.SetIdempotency(newTestIdempotencyStore(t, &IdempotencyConfig{Methods: []string{"app.Service/Create"}}))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "abc"))
+	assert.NotEmpty(t, p.idempotencyKey(ctx, "Create"))
+	assert.Empty(t, p.idempotencyKey(ctx, "Get"))
+	assert.Empty(t, p.idempotencyKey(context.Background(), "Create"))
+
+	// the same key sent by another caller does not replay the stored response
+	alice := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "abc", "authorization", "Bearer alice"))
+	bob := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "abc", "authorization", "Bearer bob"))
+	assert.NotEqual(t, p.idempotencyKey(alice, "Create"), p.idempotencyKey(bob, "Create"))
+	assert.NotEqual(t, p.idempotencyKey(ctx, "Create"), p.idempotencyKey(alice, "Create"))
+	assert.Equal(t, p.idempotencyKey(alice, "Create"), p.idempotencyKey(alice, "Create"))
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	s := newTestIdempotencyStore(t, &IdempotencyConfig{Methods: []string{"app.Service/Create"}})
+
+	var calls atomic.Int32
+	fn := func() (*response, error) {
+		calls.Add(1)
+		return &response{body: []byte("created")}, nil
+	}
+
+	for range 3 {
+		resp, err := s.do(context.Background(), "key", []byte("req"), fn)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("created"), resp.body)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	// the same key with another request is rejected
+	_, err := s.do(context.Background(), "key", []byte("other"), fn)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestIdempotencyConcurrentDuplicateWaits(t *testing.T) {
+	s := newTestIdempotencyStore(t, &IdempotencyConfig{Methods: []string{"app.Service/Create"}})
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (*response, error) {
+		calls.Add(1)
+		<-release
+		return &response{body: []byte("created")}, nil
+	}
+
+	wg := &sync.WaitGroup{}
+	for range 5 {
+		wg.Go(func() {
+			resp, err := s.do(context.Background(), "key", []byte("req"), fn)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("created"), resp.body)
+		})
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotencyFailuresAreNotStored(t *testing.T) {
+	s := newTestIdempotencyStore(t, &IdempotencyConfig{Methods: []string{"app.Service/Create"}})
+
+	var calls atomic.Int32
+	fn := func() (*response, error) {
+		calls.Add(1)
+		return &response{context: []byte(`{"error":"CAgSBGZhaWw="}`)}, nil
+	}
+
+	for range 2 {
+		_, err := s.do(context.Background(), "key", []byte("req"), fn)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIdempotencyEviction(t *testing.T) {
+	s := newTestIdempotencyStore(t, &IdempotencyConfig{Methods: []string{"app.Service/Create"}, MaxEntries: 2, TTL: time.Millisecond * 50})
+
+	fn := func() (*response, error) { return &response{}, nil }
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := s.do(context.Background(), key, nil, fn)
+		require.NoError(t, err)
+	}
+
+	s.mu.Lock()
+	assert.Len(t, s.entries, 2)
+	assert.NotContains(t, s.entries, "a")
+	s.mu.Unlock()
+
+	time.Sleep(time.Millisecond * 60)
+	_, err := s.do(context.Background(), "d", nil, fn)
+	require.NoError(t, err)
+
+	s.mu.Lock()
+	assert.Len(t, s.entries, 1)
+	s.mu.Unlock()
+}
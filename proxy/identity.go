@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/roadrunner-server/errors"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// the verified client certificate, forwarded in the rpc context
+const (
+	peerCertSubject     string = ":peer.cert.subject"
+	peerCertDNS         string = ":peer.cert.dns"
+	peerCertURI         string = ":peer.cert.uri"
+	peerCertSpiffeID    string = ":peer.cert.spiffe-id"
+	peerCertSerial      string = ":peer.cert.serial"
+	peerCertFingerprint string = ":peer.cert.fingerprint"
+	peerIdentity        string = ":peer.identity"
+)
+
+// IdentityConfig maps the SANs of the verified client certificates to the caller identities.
+type IdentityConfig struct {
+	// Rules are matched in order against the URI SANs (SPIFFE IDs included), then the DNS SANs, the first match wins.
+	Rules []*IdentityRule `mapstructure:"rules"`
+}
+
+// IdentityRule maps the SANs matching the pattern to the identity.
+type IdentityRule struct {
+	// Pattern is a regular expression matched against the whole SAN, e.g. `spiffe://cluster.local/ns/(\w+)/sa/billing`.
+	Pattern string `mapstructure:"pattern"`
+	// Identity is forwarded as `:peer.identity`, the `$1` style references are expanded from the pattern groups.
+	Identity string `mapstructure:"identity"`
+
+	re *regexp.Regexp
+}
+
+func (c *IdentityConfig) InitDefaults() error {
+	const op = errors.Op("grpc_identity_init")
+
+	for i, r := range c.Rules {
+		if r == nil || r.Pattern == "" || r.Identity == "" {
+			return errors.E(op, errors.Errorf("rules[%d]: both pattern and identity should be set", i))
+		}
+
+		re, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return errors.E(op, errors.Errorf("rules[%d]: malformed pattern %s: %v", i, r.Pattern, err))
+		}
+		r.re = re
+	}
+
+	return nil
+}
+
+// identity returns the caller identity of the first matching rule, empty when none matches.
+func (c *IdentityConfig) identity(cert *x509.Certificate) string {
+	sans := make([]string, 0, len(cert.URIs)+len(cert.DNSNames))
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	sans = append(sans, cert.DNSNames...)
+
+	for _, r := range c.Rules {
+		for _, san := range sans {
+			m := r.re.FindStringSubmatchIndex(san)
+			if m != nil {
+				return string(r.re.ExpandString(nil, r.Identity, san, m))
+			}
+		}
+	}
+
+	return ""
+}
+
+// SetIdentity assigns the mapping of the client certificates to the caller identities.
+func (p *Proxy) SetIdentity(cfg *IdentityConfig) {
+	p.identity = cfg
+}
+
+// peerCertificate returns the leaf certificate of the client, nil when the client certificate was not verified.
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	info, ok := pr.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return info.State.PeerCertificates[0]
+}
+
+// addClientIdentity adds the verified client certificate and the mapped caller identity to the rpc context.
+func (p *Proxy) addClientIdentity(ctx context.Context, ctxMD map[string][]string) {
+	cert := peerCertificate(ctx)
+	if cert == nil {
+		return
+	}
+
+	ctxMD[peerCertSubject] = []string{cert.Subject.String()}
+	ctxMD[peerCertSerial] = []string{cert.SerialNumber.Text(16)}
+	ctxMD[peerCertFingerprint] = []string{fingerprint(cert)}
+
+	if len(cert.DNSNames) > 0 {
+		ctxMD[peerCertDNS] = cert.DNSNames
+	}
+
+	for _, u := range cert.URIs {
+		ctxMD[peerCertURI] = append(ctxMD[peerCertURI], u.String())
+		if u.Scheme == "spiffe" {
+			ctxMD[peerCertSpiffeID] = append(ctxMD[peerCertSpiffeID], u.String())
+		}
+	}
+
+	if p.identity != nil {
+		if id := p.identity.identity(cert); id != "" {
+			ctxMD[peerIdentity] = []string{id}
+		}
+	}
+}
+
+// fingerprint is the hex SHA-256 of the DER certificate.
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
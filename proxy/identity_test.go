@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func tlsPeerContext(cert *x509.Certificate, verified bool) context.Context {
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if verified {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000},
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+}
+
+func TestClientIdentity(t *testing.T) {
+	spiffe, err := url.Parse("spiffe://cluster.local/ns/payments/sa/billing")
+	require.NoError(t, err)
+
+	cert := &x509.Certificate{
+		Raw:          []byte("certificate"),
+		SerialNumber: big.NewInt(0xabc),
+		Subject:      pkix.Name{CommonName: "billing", Organization: []string{"Acme"}},
+		DNSNames:     []string{"billing.payments.svc"},
+		URIs:         []*url.URL{spiffe},
+	}
+
+	cfg := &IdentityConfig{Rules: []*IdentityRule{
+		{Pattern: `spiffe://cluster\.local/ns/(\w+)/sa/(\w+)`, Identity: "$1/$2"},
+		{Pattern: `.*\.svc`, Identity: "internal"},
+	}}
+	require.NoError(t, cfg.InitDefaults())
+
+	p := newTestProxy("app.Service")
+	p.SetIdentity(cfg)
+
+	data, err := json.Marshal(p.rpcContext(tlsPeerContext(cert, true), "Method"))
+	require.NoError(t, err)
+
+	rc := &rpcContext{}
+	require.NoError(t, json.Unmarshal(data, rc))
+	assert.Equal(t, []string{"CN=billing,O=Acme"}, rc.Context[peerCertSubject])
+	assert.Equal(t, []string{"billing.payments.svc"}, rc.Context[peerCertDNS])
+	assert.Equal(t, []string{spiffe.String()}, rc.Context[peerCertURI])
+	assert.Equal(t, []string{spiffe.String()}, rc.Context[peerCertSpiffeID])
+	assert.Equal(t, []string{"abc"}, rc.Context[peerCertSerial])
+	assert.Equal(t, []string{fingerprint(cert)}, rc.Context[peerCertFingerprint])
+	assert.Len(t, rc.Context[peerCertFingerprint][0], 64)
+	assert.Equal(t, []string{"payments/billing"}, rc.Context[peerIdentity])
+
+	// the DNS SANs are matched when no URI does
+	cert.URIs = nil
+	rc = p.rpcContext(tlsPeerContext(cert, true), "Method")
+	assert.Equal(t, []string{"internal"}, rc.Context[peerIdentity])
+	assert.Empty(t, rc.Context[peerCertSpiffeID])
+
+	// the patterns match the whole SAN
+	cert.DNSNames = []string{"billing.svc.example.com"}
+	rc = p.rpcContext(tlsPeerContext(cert, true), "Method")
+	assert.Empty(t, rc.Context[peerIdentity])
+	assert.NotEmpty(t, rc.Context[peerCertFingerprint])
+
+	// the unverified certificates are not forwarded
+	rc = p.rpcContext(tlsPeerContext(cert, false), "Method")
+	assert.Equal(t, []string{"tls"}, rc.Context[peerAuthType])
+	assert.Empty(t, rc.Context[peerCertSubject])
+	assert.Empty(t, rc.Context[peerCertFingerprint])
+}
+
+func TestIdentityConfigInitDefaults(t *testing.T) {
+	cfg := &IdentityConfig{Rules: []*IdentityRule{{Pattern: "(", Identity: "x"}}}
+	assert.ErrorContains(t, cfg.InitDefaults(), "malformed pattern")
+
+	cfg = &IdentityConfig{Rules: []*IdentityRule{{Pattern: "x"}}}
+	assert.ErrorContains(t, cfg.InitDefaults(), "both pattern and identity should be set")
+}
+
+func TestRequestKeyClientCertificate(t *testing.T) {
+	first := &x509.Certificate{Raw: []byte("first")}
+	second := &x509.Certificate{Raw: []byte("second")}
+
+	body := []byte("body")
+	assert.Equal(t, requestKey(tlsPeerContext(first, true), "/app.Service/Method", nil, body), requestKey(tlsPeerContext(first, true), "/app.Service/Method", nil, body))
+	assert.NotEqual(t, requestKey(tlsPeerContext(first, true), "/app.Service/Method", nil, body), requestKey(tlsPeerContext(second, true), "/app.Service/Method", nil, body))
+	assert.Equal(t, requestKey(context.Background(), "/app.Service/Method", nil, body), requestKey(tlsPeerContext(first, false), "/app.Service/Method", nil, body))
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+	"github.com/roadrunner-server/grpc/v6/operations"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const typeURLPrefix string = "type.googleapis.com/"
+
+// LongRunningConfig configures the methods returning a google.longrunning.Operation. The worker call is executed
+// in the background and its response is packed into the operation's response.
+type LongRunningConfig struct {
+	// Methods are the long-running methods.
+	Methods []*LongRunningMethod `mapstructure:"methods"`
+	// Retention is the time the finished operations are kept. Defaults to 1h.
+	Retention time.Duration `mapstructure:"retention"`
+	// MaxOperations limits the number of the stored operations. Defaults to 10000.
+	MaxOperations int `mapstructure:"max_operations"`
+	// Timeout limits the worker call. Defaults to 1h.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// LongRunningMethod is a method returning a google.longrunning.Operation.
+type LongRunningMethod struct {
+	// Method is the fully-qualified method name, e.g. `package.Service/Method`.
+	Method string `mapstructure:"method"`
+	// ResponseType is the fully-qualified message name of the worker response, e.g. `package.Result`.
+	ResponseType string `mapstructure:"response_type"`
+}
+
+func (c *LongRunningConfig) InitDefaults() error {
+	const op = errors.Op("grpc_long_running_init")
+
+	for _, m := range c.Methods {
+		if m == nil {
+			return errors.E(op, errors.Str("empty long-running method"))
+		}
+
+		var err error
+		m.Method, err = methodname.Normalize(m.Method)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if m.ResponseType == "" {
+			return errors.E(op, errors.Errorf("response_type is required for the method: %s", m.Method))
+		}
+	}
+
+	if c.Retention == 0 {
+		c.Retention = time.Hour
+	}
+
+	if c.MaxOperations == 0 {
+		c.MaxOperations = 10000
+	}
+
+	if c.Timeout == 0 {
+		c.Timeout = time.Hour
+	}
+
+	if c.Retention < 0 || c.MaxOperations < 0 || c.Timeout < 0 {
+		return errors.E(op, errors.Str("retention, max_operations and timeout should not be negative"))
+	}
+
+	return nil
+}
+
+// SetOperations assigns the store of the long-running operations for the configured methods of the service.
+func (p *Proxy) SetOperations(store *operations.Store, cfg *LongRunningConfig) {
+	if store == nil || cfg == nil {
+		return
+	}
+
+	p.operations = store
+	p.operationsTimeout = cfg.Timeout
+	p.longRunning = make(map[string]string)
+
+	for _, m := range cfg.Methods {
+		service, method, _ := strings.Cut(m.Method, "/")
+		if service == p.name {
+			p.longRunning[method] = m.ResponseType
+		}
+	}
+}
+
+func (p *Proxy) isLongRunning(method string) bool {
+	_, ok := p.longRunning[method]
+	return ok
+}
+
+// startOperation runs the call in the background and responds with the created google.longrunning.Operation.
+func (p *Proxy) startOperation(ctx context.Context, method string, in *codec.RawMessage) (any, error) {
+	pld, err := p.detachedPayload(ctx, method, in)
+	if err != nil {
+		return nil, err
+	}
+
+	typeURL := typeURLPrefix + p.longRunning[method]
+	op, err := p.operations.Start(p.operationsTimeout, func(ctx context.Context) (*anypb.Any, error) {
+		resp, errE := p.execPayload(ctx, pld)
+		if errE != nil {
+			return nil, errE
+		}
+
+		errE = responseError(resp.context)
+		if errE != nil {
+			return nil, errE
+		}
+
+		return &anypb.Any{TypeUrl: typeURL, Value: resp.body}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := operations.MarshalOperation(op)
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.RawMessage(out), nil
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/grpc/v6/operations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestLongRunningConfigInitDefaults(t *testing.T) {
+	cfg := &LongRunningConfig{Methods: []*LongRunningMethod{{Method: "/app.Service/Export", ResponseType: "app.Result"}}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, "app.Service/Export", cfg.Methods[0].Method)
+	assert.Equal(t, time.Hour, cfg.Retention)
+	assert.Equal(t, 10000, cfg.MaxOperations)
+	assert.Equal(t, time.Hour, cfg.Timeout)
+
+	assert.Error(t, (&LongRunningConfig{Methods: []*LongRunningMethod{{Method: "Export", ResponseType: "app.Result"}}}).InitDefaults())
+	assert.Error(t, (&LongRunningConfig{Methods: []*LongRunningMethod{{Method: "app.Service/Export"}}}).InitDefaults())
+	assert.Error(t, (&LongRunningConfig{Methods: []*LongRunningMethod{nil}}).InitDefaults())
+	assert.Error(t, (&LongRunningConfig{MaxOperations: -1}).InitDefaults())
+}
+
+func TestStartOperation(t *testing.T) {
+	cfg := &LongRunningConfig{Methods: []*LongRunningMethod{
+		{Method: "app.Service/Export", ResponseType: "app.Result"},
+		{Method: "app.Other/Export", ResponseType: "app.Result"},
+	}}
+	require.NoError(t, cfg.InitDefaults())
+
+	store := operations.NewStore(cfg.Retention, cfg.MaxOperations, slog.New(slog.DiscardHandler))
+	defer func() { _ = store.Stop(context.Background(), time.Second) }()
+
+	pool := &failingPool{}
+	p := newTestProxy("app.Service")
+	p.grpcPool = pool
+	p.SetOperations(store, cfg)
+
+	require.True(t, p.isLongRunning("Export"))
+	require.False(t, p.isLongRunning("Get"))
+	require.Len(t, p.longRunning, 1)
+
+	in := codec.RawMessage("job")
+	resp, err := p.invoke(context.Background(), "Export", &in)
+	require.NoError(t, err)
+	require.IsType(t, codec.RawMessage{}, resp)
+
+	page, _, err := store.List("", 0, "")
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+
+	expected, err := operations.MarshalOperation(page[0])
+	require.NoError(t, err)
+	assert.Equal(t, codec.RawMessage(expected), resp)
+
+	// the worker error is reported in the operation
+	op, err := store.Wait(context.Background(), page[0].Name, 0)
+	require.NoError(t, err)
+	assert.True(t, op.Done)
+	assert.Equal(t, int32(codes.Internal), op.Error.GetCode())
+	assert.Equal(t, int32(1), pool.calls.Load())
+}
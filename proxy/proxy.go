@@ -2,6 +2,7 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -11,14 +12,18 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	_ "google.golang.org/genproto/protobuf/ptype" //nolint:revive,nolintlint
 
 	"maps"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/roadrunner-server/errors"
 	"github.com/roadrunner-server/goridge/v4/pkg/frame"
 	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/roadrunner-server/grpc/v6/compression"
+	"github.com/roadrunner-server/grpc/v6/operations"
 	"github.com/roadrunner-server/pool/v2/payload"
 	"github.com/roadrunner-server/pool/v2/pool/static_pool"
 	"github.com/roadrunner-server/pool/v2/worker"
@@ -79,6 +84,35 @@ type Proxy struct {
 	metadata string
 	methods  []string
 
+	// faults are the injected faults per method
+	faults map[string][]*FaultRule
+	// idempotency replays the stored responses for the retried calls
+	idempotency *IdempotencyStore
+	// coalescer shares a worker execution between the identical concurrent calls
+	coalescer *Coalescer
+	coalesced map[string]struct{}
+	// cache keeps the responses of the side-effect-free methods
+	cache *ResponseCache
+	// async executes the fire-and-forget calls in the background
+	async *AsyncDispatcher
+	// batcher groups the concurrent calls into a single worker call
+	batcher *Batcher
+	// hedger sends the slow calls to a second worker
+	hedger *Hedger
+	hedged map[string]struct{}
+	// operations keeps the background calls of the long-running methods, mapped to their response types
+	operations        *operations.Store
+	operationsTimeout time.Duration
+	longRunning       map[string]string
+	// identity maps the verified client certificates to the caller identities
+	identity *IdentityConfig
+	// compression are the response compression rules per method
+	compression map[string]*compression.Rule
+	// sizeLimits are the decompressed request size limits per method
+	sizeLimits     map[string]int
+	sizeRejections *prometheus.CounterVec
+	sizeRequests   *prometheus.CounterVec
+
 	pldPool sync.Pool
 }
 
@@ -146,6 +180,10 @@ func (p *Proxy) methodHandler(method string) func(srv any, ctx context.Context,
 			return nil, wrapError(err)
 		}
 
+		if err := p.checkSize(method, len(*in)); err != nil {
+			return nil, err
+		}
+
 		if interceptor == nil {
 			return p.invoke(ctx, method, in)
 		}
@@ -169,17 +207,110 @@ func (p *Proxy) methodHandler(method string) func(srv any, ctx context.Context,
 }
 
 func (p *Proxy) invoke(ctx context.Context, method string, in *codec.RawMessage) (any, error) {
-	pld := p.getPld()
-	defer p.putPld(pld)
+	err := p.injectFault(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isAsync(method) {
+		return p.enqueue(ctx, method, in)
+	}
+
+	if p.isLongRunning(method) {
+		return p.startOperation(ctx, method, in)
+	}
+
+	resp, err := p.call(ctx, method, in)
+	if err != nil {
+		return nil, err
+	}
 
 	// experimental grpc API
 	st := grpc.ServerTransportStreamFromContext(ctx)
 
+	err = p.responseMetadata(st, resp.context)
+	if err != nil {
+		return nil, err
+	}
+
+	p.setCompressor(ctx, method, len(resp.body))
+
+	return codec.RawMessage(resp.body), nil
+}
+
+// call executes the request on a worker, deduplicating, caching, coalescing, batching or hedging it when configured.
+func (p *Proxy) call(ctx context.Context, method string, in *codec.RawMessage) (*response, error) {
+	exec := func() (*response, error) {
+		return p.exec(ctx, method, in)
+	}
+
+	switch {
+	case p.isBatched(method):
+		exec = func() (*response, error) {
+			return p.batched(ctx, method, in)
+		}
+	case p.isHedged(method):
+		exec = func() (*response, error) {
+			return p.hedge(ctx, method, func(ctx context.Context) (*response, error) {
+				return p.exec(ctx, method, in)
+			})
+		}
+	}
+
+	if key := p.idempotencyKey(ctx, method); key != "" {
+		return p.idempotency.do(ctx, key, *in, exec)
+	}
+
+	if p.isCoalesced(method) {
+		coalesced := exec
+		exec = func() (*response, error) {
+			return p.coalescer.do(ctx, requestKey(ctx, p.name+"/"+method, p.coalescer.metadata, *in), coalesced)
+		}
+	}
+
+	if p.isCached(method) {
+		return p.cache.fetch(ctx, p.name+"/"+method, *in, exec)
+	}
+
+	return exec()
+}
+
+// response is a worker reply detached from the pooled payload, so it might be kept after the call is finished.
+type response struct {
+	body    []byte
+	context []byte
+}
+
+// failed reports whether the worker finished the call with an error (PHP exception).
+func (r *response) failed() bool {
+	if len(r.context) == 0 {
+		return false
+	}
+
+	var rpcMetadata map[string]string
+	if err := json.Unmarshal(r.context, &rpcMetadata); err != nil {
+		return true
+	}
+
+	_, ok := rpcMetadata[apiErr]
+	return ok
+}
+
+// exec sends the request to a worker and waits for the response.
+func (p *Proxy) exec(ctx context.Context, method string, in *codec.RawMessage) (*response, error) {
+	pld := p.getPld()
+	defer p.putPld(pld)
+
 	err := p.makePayload(ctx, method, in, pld)
 	if err != nil {
 		return nil, err
 	}
 
+	return p.execPayload(ctx, pld)
+}
+
+// execPayload sends the prepared payload to a worker and waits for the response.
+func (p *Proxy) execPayload(ctx context.Context, pld *payload.Payload) (*response, error) {
 	p.mu.RLock()
 	re, err := p.grpcPool.Exec(ctx, pld, nil)
 	p.mu.RUnlock()
@@ -187,8 +318,6 @@ func (p *Proxy) invoke(ctx context.Context, method string, in *codec.RawMessage)
 		return nil, wrapError(err)
 	}
 
-	var r *payload.Payload
-
 	select {
 	case pl := <-re:
 		if pl.Error() != nil {
@@ -199,28 +328,20 @@ func (p *Proxy) invoke(ctx context.Context, method string, in *codec.RawMessage)
 			return nil, errors.Str("streaming is not supported")
 		}
 
-		// assign the payload
-		r = pl.Payload()
+		return &response{body: pl.Payload().Body, context: pl.Payload().Context}, nil
 	default:
 		return nil, errors.Str("worker empty response")
 	}
-
-	err = p.responseMetadata(st, r)
-	if err != nil {
-		return nil, err
-	}
-
-	return codec.RawMessage(r.Body), nil
 }
 
 // responseMetadata extracts metadata from roadrunner response Payload.Context and converts it to metadata.MD
-func (p *Proxy) responseMetadata(st grpc.ServerTransportStream, resp *payload.Payload) error {
-	if resp == nil || len(resp.Context) == 0 {
+func (p *Proxy) responseMetadata(st grpc.ServerTransportStream, rctx []byte) error {
+	if len(rctx) == 0 {
 		return nil
 	}
 
 	var rpcMetadata map[string]string
-	err := json.Unmarshal(resp.Context, &rpcMetadata)
+	err := json.Unmarshal(rctx, &rpcMetadata)
 	if err != nil {
 		return err
 	}
@@ -305,8 +426,36 @@ func (p *Proxy) responseMetadata(st grpc.ServerTransportStream, resp *payload.Pa
 	return nil
 }
 
+// detachedPayload makes the payload of the call executed in the background. It outlives the call, so neither
+// the payload is taken from the pool nor the body shares the request buffer.
+func (p *Proxy) detachedPayload(ctx context.Context, method string, in *codec.RawMessage) (*payload.Payload, error) {
+	pld := &payload.Payload{Codec: frame.CodecJSON}
+
+	body := codec.RawMessage(bytes.Clone(*in))
+	err := p.makePayload(ctx, method, &body, pld)
+	if err != nil {
+		return nil, err
+	}
+
+	return pld, nil
+}
+
 // makePayload generates a RoadRunner compatible payload based on a GRPC message.
 func (p *Proxy) makePayload(ctx context.Context, method string, body *codec.RawMessage, pld *payload.Payload) error {
+	ctxData, err := json.Marshal(p.rpcContext(ctx, method))
+
+	if err != nil {
+		return err
+	}
+
+	pld.Body = *body
+	pld.Context = ctxData
+
+	return nil
+}
+
+// rpcContext collects the service, method and incoming metadata of the call.
+func (p *Proxy) rpcContext(ctx context.Context, method string) *rpcContext {
 	ctxMD := make(map[string][]string)
 
 	p.prop.Inject(ctx, propagation.HeaderCarrier(ctxMD))
@@ -321,16 +470,9 @@ func (p *Proxy) makePayload(ctx context.Context, method string, body *codec.RawM
 		}
 	}
 
-	ctxData, err := json.Marshal(rpcContext{Service: p.name, Method: method, Context: ctxMD})
-
-	if err != nil {
-		return err
-	}
-
-	pld.Body = *body
-	pld.Context = ctxData
+	p.addClientIdentity(ctx, ctxMD)
 
-	return nil
+	return &rpcContext{Service: p.name, Method: method, Context: ctxMD}
 }
 
 func (p *Proxy) putPld(pld *payload.Payload) {
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/internal/methodname"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SizeLimitConfig limits the decompressed size of the requests. The requests over the limit are rejected with
+// RESOURCE_EXHAUSTED before they reach the interceptors and are copied to the worker payload. The decompression
+// is stopped past the largest of the limits, see Bound.
+type SizeLimitConfig struct {
+	// MaxBytes limits the decompressed requests of all methods, unlimited when zero.
+	MaxBytes int `mapstructure:"max_bytes"`
+	// Methods override the limit of the single methods.
+	Methods []*MethodSizeLimit `mapstructure:"methods"`
+}
+
+// MethodSizeLimit limits the decompressed requests of a single method.
+type MethodSizeLimit struct {
+	// Method is the fully-qualified method name, e.g. `package.Service/Method`.
+	Method string `mapstructure:"method"`
+	// MaxBytes limits the decompressed requests of the method, unlimited when zero.
+	MaxBytes int `mapstructure:"max_bytes"`
+}
+
+func (c *SizeLimitConfig) InitDefaults() error {
+	const op = errors.Op("grpc_size_limit_init")
+
+	if c.MaxBytes < 0 {
+		return errors.E(op, errors.Str("max_bytes should not be negative"))
+	}
+
+	for i, m := range c.Methods {
+		if m == nil {
+			return errors.E(op, errors.Errorf("methods[%d]: limit should not be empty", i))
+		}
+
+		var err error
+		m.Method, err = methodname.Normalize(m.Method)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if m.MaxBytes < 0 {
+			return errors.E(op, errors.Errorf("max_bytes should not be negative, method: %s", m.Method))
+		}
+	}
+
+	return nil
+}
+
+// Bound returns the largest of the limits, zero when any of the methods is unlimited. The decompressor knows
+// nothing about the method, so it can only be bounded by the largest limit, the method limits are checked after.
+func (c *SizeLimitConfig) Bound() int {
+	if c.MaxBytes == 0 {
+		return 0
+	}
+
+	bound := c.MaxBytes
+	for _, m := range c.Methods {
+		if m.MaxBytes == 0 {
+			return 0
+		}
+
+		bound = max(bound, m.MaxBytes)
+	}
+
+	return bound
+}
+
+// SetSizeLimits assigns the request size limits of the registered methods, the rejected requests are counted by
+// the rejections counter with the `grpc_method` label and by the requests counter with the `grpc_method` and
+// `status_code` labels. Should be called after the methods are registered.
+func (p *Proxy) SetSizeLimits(cfg *SizeLimitConfig, rejections, requests *prometheus.CounterVec) {
+	if cfg == nil {
+		return
+	}
+
+	p.sizeLimits = make(map[string]int, len(p.methods))
+	for _, m := range p.methods {
+		p.sizeLimits[m] = cfg.MaxBytes
+	}
+
+	for _, m := range cfg.Methods {
+		service, method, _ := strings.Cut(m.Method, "/")
+		if _, ok := p.sizeLimits[method]; ok && service == p.name {
+			p.sizeLimits[method] = m.MaxBytes
+		}
+	}
+
+	p.sizeRejections = rejections
+	p.sizeRequests = requests
+}
+
+// checkSize rejects the decompressed request over the method limit.
+func (p *Proxy) checkSize(method string, size int) error {
+	limit := p.sizeLimits[method]
+	if limit == 0 || size <= limit {
+		return nil
+	}
+
+	fullMethod := fmt.Sprintf("/%s/%s", p.name, method)
+	if p.sizeRejections != nil {
+		p.sizeRejections.WithLabelValues(fullMethod).Inc()
+	}
+
+	// the interceptor counting the requests is not reached
+	if p.sizeRequests != nil {
+		p.sizeRequests.WithLabelValues(fullMethod, codes.ResourceExhausted.String()).Inc()
+	}
+
+	p.log.Warn("request was rejected, decompressed size is over the limit", "method", fullMethod, "size", size, "limit", limit)
+
+	return status.Errorf(codes.ResourceExhausted, "decompressed message is larger than max (%d vs. %d)", size, limit)
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/roadrunner-server/grpc/v6/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSizeLimitConfigInitDefaults(t *testing.T) {
+	c := &SizeLimitConfig{MaxBytes: 1024, Methods: []*MethodSizeLimit{{Method: "/app.Service/Upload", MaxBytes: 4096}}}
+	require.NoError(t, c.InitDefaults())
+	assert.Equal(t, "app.Service/Upload", c.Methods[0].Method)
+
+	assert.Error(t, (&SizeLimitConfig{MaxBytes: -1}).InitDefaults())
+	assert.Error(t, (&SizeLimitConfig{Methods: []*MethodSizeLimit{{Method: "Upload"}}}).InitDefaults())
+	assert.Error(t, (&SizeLimitConfig{Methods: []*MethodSizeLimit{{Method: "app.Service/Upload", MaxBytes: -1}}}).InitDefaults())
+}
+
+func TestSizeLimits(t *testing.T) {
+	p := newTestProxy("app.Service")
+	p.RegisterMethod("Get")
+	p.RegisterMethod("Upload")
+	p.RegisterMethod("Import")
+
+	cfg := &SizeLimitConfig{
+		MaxBytes: 8,
+		Methods: []*MethodSizeLimit{
+			{Method: "app.Service/Upload", MaxBytes: 16},
+			{Method: "app.Service/Import"},
+			{Method: "app.Other/Get", MaxBytes: 1024},
+		},
+	}
+	require.NoError(t, cfg.InitDefaults())
+
+	rejections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "r"}, []string{"grpc_method"})
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "q"}, []string{"grpc_method", "status_code"})
+	p.SetSizeLimits(cfg, rejections, requests)
+
+	// the interceptor is not reached by the rejected requests
+	var called int
+	interceptor := func(context.Context, any, *grpc.UnaryServerInfo, grpc.UnaryHandler) (any, error) {
+		called++
+		return codec.RawMessage{}, nil
+	}
+
+	call := func(method string, size int) error {
+		dec := func(v any) error {
+			*v.(*codec.RawMessage) = make([]byte, size)
+			return nil
+		}
+		_, err := p.methodHandler(method)(p, context.Background(), dec, interceptor)
+		return err
+	}
+
+	require.NoError(t, call("Get", 8))
+	err := call("Get", 9)
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// overridden per method
+	require.NoError(t, call("Upload", 16))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("Upload", 17)))
+	require.NoError(t, call("Import", 1<<20))
+
+	assert.Equal(t, 3, called)
+	assert.Equal(t, float64(1), testutil.ToFloat64(rejections.WithLabelValues("/app.Service/Get")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(rejections.WithLabelValues("/app.Service/Upload")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues("/app.Service/Get", "ResourceExhausted")))
+	assert.Equal(t, 2, testutil.CollectAndCount(requests))
+
+	// no limits
+	p = newTestProxy("app.Service")
+	p.RegisterMethod("Get")
+	p.SetSizeLimits(nil, rejections, requests)
+	require.NoError(t, call("Get", 1<<20))
+}
+
+func TestSizeLimitBound(t *testing.T) {
+	assert.Equal(t, 0, (&SizeLimitConfig{}).Bound())
+	assert.Equal(t, 0, (&SizeLimitConfig{Methods: []*MethodSizeLimit{{Method: "app.Service/Upload", MaxBytes: 16}}}).Bound())
+	assert.Equal(t, 8, (&SizeLimitConfig{MaxBytes: 8}).Bound())
+	assert.Equal(t, 16, (&SizeLimitConfig{MaxBytes: 8, Methods: []*MethodSizeLimit{{Method: "app.Service/Upload", MaxBytes: 16}}}).Bound())
+
+	// an unlimited method
+	assert.Equal(t, 0, (&SizeLimitConfig{MaxBytes: 8, Methods: []*MethodSizeLimit{{Method: "app.Service/Import"}}}).Bound())
+}
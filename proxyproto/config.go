@@ -0,0 +1,70 @@
+// Package proxyproto decodes the PROXY protocol (v1 and v2) header sent by the load balancers, so the accepted
+// connections report the original client address. See https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+package proxyproto
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Config configures the PROXY protocol decoding on the gRPC listeners.
+type Config struct {
+	// Trusted are the CIDRs (or the single addresses) of the load balancers allowed to send the PROXY header.
+	// The connections from other sources are served as is.
+	Trusted []string `mapstructure:"trusted"`
+	// HeaderTimeout limits the time to read the header. Defaults to 5s.
+	HeaderTimeout time.Duration `mapstructure:"header_timeout"`
+
+	trusted []netip.Prefix
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_proxy_protocol_init")
+
+	if len(c.Trusted) == 0 {
+		return errors.E(op, errors.Str("at least one trusted CIDR is required for the PROXY protocol"))
+	}
+
+	c.trusted = make([]netip.Prefix, 0, len(c.Trusted))
+	for _, cidr := range c.Trusted {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return errors.E(op, errors.Errorf("malformed trusted address %s: %v", cidr, err))
+			}
+			c.trusted = append(c.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return errors.E(op, errors.Errorf("malformed trusted CIDR %s: %v", cidr, err))
+		}
+		c.trusted = append(c.trusted, prefix.Masked())
+	}
+
+	if c.HeaderTimeout == 0 {
+		c.HeaderTimeout = time.Second * 5
+	}
+
+	if c.HeaderTimeout < 0 {
+		return errors.E(op, errors.Str("header_timeout should not be negative"))
+	}
+
+	return nil
+}
+
+// isTrusted reports whether the connection source is a trusted load balancer.
+func (c *Config) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range c.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
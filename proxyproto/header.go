@@ -0,0 +1,171 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	v1Prefix string = "PROXY "
+	// the longest v1 header, including CRLF
+	v1MaxLength int = 107
+
+	v2HeaderLength int  = 16
+	v2Version      byte = 0x20
+	v2CmdLocal     byte = 0x00
+	v2CmdProxy     byte = 0x01
+	v2FamilyInet   byte = 0x10
+	v2FamilyInet6  byte = 0x20
+	v2ProtoStream  byte = 0x01
+	// the source and destination addresses and ports
+	v2Inet4Length int = 12
+	v2Inet6Length int = 36
+)
+
+// the results of the header decoding, reported in the connections metric
+const (
+	resultV1        string = "v1"
+	resultV2        string = "v2"
+	resultLocal     string = "local"
+	resultNone      string = "none"
+	resultUntrusted string = "untrusted"
+	resultError     string = "error"
+)
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n") //nolint:gochecknoglobals
+
+// readHeader reads the PROXY header, when present, and returns the original source address. The address is nil
+// when the header is absent or it does not carry the TCP addresses (LOCAL, UNKNOWN, UDP, unix).
+func readHeader(r *bufio.Reader) (net.Addr, string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, resultError, err
+	}
+
+	switch first[0] {
+	case v1Prefix[0]:
+		prefix, errP := r.Peek(len(v1Prefix))
+		if errP != nil || string(prefix) != v1Prefix {
+			// not a header, e.g. the HTTP/2 client preface
+			return nil, resultNone, nil
+		}
+
+		return readV1(r)
+	case v2Signature[0]:
+		sig, errP := r.Peek(len(v2Signature))
+		if errP != nil || !bytes.Equal(sig, v2Signature) {
+			return nil, resultNone, nil
+		}
+
+		return readV2(r)
+	default:
+		return nil, resultNone, nil
+	}
+}
+
+// readV1 parses the text header: PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n
+func readV1(r *bufio.Reader) (net.Addr, string, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= v1MaxLength {
+			return nil, resultError, errors.Str("PROXY v1 header is too long")
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, resultError, err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 {
+		return nil, resultError, errors.Str("malformed PROXY v1 header")
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, resultLocal, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, resultError, errors.Errorf("unsupported PROXY v1 protocol %s", fields[1])
+	}
+
+	if len(fields) != 6 {
+		return nil, resultError, errors.Str("malformed PROXY v1 header")
+	}
+
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil || ip.Is4() != (fields[1] == "TCP4") {
+		return nil, resultError, errors.Errorf("malformed PROXY v1 source address %s", fields[2])
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, resultError, errors.Errorf("malformed PROXY v1 source port %s", fields[4])
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), resultV1, nil
+}
+
+// readV2 parses the binary header, the TLVs are skipped.
+func readV2(r *bufio.Reader) (net.Addr, string, error) {
+	hdr := make([]byte, v2HeaderLength)
+	_, err := io.ReadFull(r, hdr)
+	if err != nil {
+		return nil, resultError, err
+	}
+
+	if hdr[12]&0xF0 != v2Version {
+		return nil, resultError, errors.Errorf("unsupported PROXY v2 version %#x", hdr[12]>>4)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return nil, resultError, err
+	}
+
+	switch hdr[12] & 0x0F {
+	case v2CmdLocal:
+		// health checks of the load balancer
+		return nil, resultLocal, nil
+	case v2CmdProxy:
+	default:
+		return nil, resultError, errors.Errorf("unsupported PROXY v2 command %#x", hdr[12]&0x0F)
+	}
+
+	if hdr[13]&0x0F != v2ProtoStream {
+		return nil, resultLocal, nil
+	}
+
+	var ip netip.Addr
+	var port uint16
+	switch hdr[13] & 0xF0 {
+	case v2FamilyInet:
+		if len(body) < v2Inet4Length {
+			return nil, resultError, errors.Str("truncated PROXY v2 IPv4 addresses")
+		}
+		ip = netip.AddrFrom4([4]byte(body[0:4]))
+		port = binary.BigEndian.Uint16(body[8:10])
+	case v2FamilyInet6:
+		if len(body) < v2Inet6Length {
+			return nil, resultError, errors.Str("truncated PROXY v2 IPv6 addresses")
+		}
+		ip = netip.AddrFrom16([16]byte(body[0:16]))
+		port = binary.BigEndian.Uint16(body[32:34])
+	default:
+		// unix sockets and the unspecified family
+		return nil, resultLocal, nil
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), resultV2, nil
+}
@@ -0,0 +1,128 @@
+package proxyproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// v2Header builds the binary header with the command, the family/protocol byte and the address block.
+func v2Header(cmd, family byte, addresses []byte) []byte {
+	hdr := append([]byte{}, v2Signature...)
+	hdr = append(hdr, v2Version|cmd, family)
+	hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(addresses)))
+	return append(hdr, addresses...)
+}
+
+func TestReadHeaderV1(t *testing.T) {
+	tests := []struct {
+		header string
+		addr   string
+		result string
+		err    bool
+	}{
+		{"PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n", "192.0.2.1:56324", resultV1, false},
+		{"PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", "[2001:db8::1]:56324", resultV1, false},
+		{"PROXY UNKNOWN\r\n", "", resultLocal, false},
+		{"PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n", "", resultLocal, false},
+		{"PROXY TCP4 2001:db8::1 198.51.100.1 56324 443\r\n", "", resultError, true},
+		{"PROXY TCP4 192.0.2.1 198.51.100.1 port 443\r\n", "", resultError, true},
+		{"PROXY TCP4 192.0.2.1\r\n", "", resultError, true},
+		{"PROXY UDP4 192.0.2.1 198.51.100.1 56324 443\r\n", "", resultError, true},
+		{"PROXY " + strings.Repeat("A", 120) + "\r\n", "", resultError, true},
+	}
+
+	for _, tt := range tests {
+		r := bufio.NewReader(strings.NewReader(tt.header + "payload"))
+		addr, result, err := readHeader(r)
+
+		assert.Equal(t, tt.result, result, tt.header)
+		if tt.err {
+			assert.Error(t, err, tt.header)
+			continue
+		}
+
+		require.NoError(t, err, tt.header)
+		if tt.addr == "" {
+			assert.Nil(t, addr)
+		} else {
+			assert.Equal(t, tt.addr, addr.String())
+		}
+
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "payload", string(rest))
+	}
+}
+
+func TestReadHeaderV2(t *testing.T) {
+	inet4 := []byte{192, 0, 2, 1, 198, 51, 100, 1}
+	inet4 = binary.BigEndian.AppendUint16(inet4, 56324)
+	inet4 = binary.BigEndian.AppendUint16(inet4, 443)
+	// a TLV after the addresses is skipped
+	inet4 = append(inet4, 0x04, 0x00, 0x01, 0xFF)
+
+	inet6 := make([]byte, 32)
+	copy(inet6, net.ParseIP("2001:db8::1").To16())
+	copy(inet6[16:], net.ParseIP("2001:db8::2").To16())
+	inet6 = binary.BigEndian.AppendUint16(inet6, 56324)
+	inet6 = binary.BigEndian.AppendUint16(inet6, 443)
+
+	tests := []struct {
+		name   string
+		header []byte
+		addr   string
+		result string
+		err    bool
+	}{
+		{"inet4", v2Header(v2CmdProxy, v2FamilyInet|v2ProtoStream, inet4), "192.0.2.1:56324", resultV2, false},
+		{"inet6", v2Header(v2CmdProxy, v2FamilyInet6|v2ProtoStream, inet6), "[2001:db8::1]:56324", resultV2, false},
+		{"local", v2Header(v2CmdLocal, 0, nil), "", resultLocal, false},
+		{"udp", v2Header(v2CmdProxy, v2FamilyInet|0x02, inet4), "", resultLocal, false},
+		{"truncated", v2Header(v2CmdProxy, v2FamilyInet6|v2ProtoStream, inet4), "", resultError, true},
+		{"command", v2Header(0x0F, v2FamilyInet|v2ProtoStream, inet4), "", resultError, true},
+	}
+
+	for _, tt := range tests {
+		r := bufio.NewReader(strings.NewReader(string(tt.header) + "payload"))
+		addr, result, err := readHeader(r)
+
+		assert.Equal(t, tt.result, result, tt.name)
+		if tt.err {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+
+		require.NoError(t, err, tt.name)
+		if tt.addr == "" {
+			assert.Nil(t, addr, tt.name)
+		} else {
+			assert.Equal(t, tt.addr, addr.String(), tt.name)
+		}
+
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "payload", string(rest), tt.name)
+	}
+}
+
+func TestReadHeaderNone(t *testing.T) {
+	for _, data := range []string{"PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n", "\x16\x03\x01", "\r\n\r\nGET / HTTP/1.1\r\n"} {
+		r := bufio.NewReader(strings.NewReader(data))
+		addr, result, err := readHeader(r)
+		require.NoError(t, err)
+		assert.Nil(t, addr)
+		assert.Equal(t, resultNone, result)
+
+		// nothing is consumed
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, data, string(rest))
+	}
+}
@@ -0,0 +1,123 @@
+package proxyproto
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Listener decodes the PROXY header of the connections from the trusted sources. The header is read on the
+// first use of the connection (Read, RemoteAddr), so a slow client does not block the accept loop.
+type Listener struct {
+	net.Listener
+	cfg *Config
+	// connections counts the accepted connections by the decoding result
+	connections *prometheus.CounterVec
+}
+
+// NewListener wraps the listener, connections might be nil.
+func NewListener(l net.Listener, cfg *Config, connections *prometheus.CounterVec) *Listener {
+	return &Listener{
+		Listener:    l,
+		cfg:         cfg,
+		connections: connections,
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{Conn: c, listener: l}, nil
+}
+
+func (l *Listener) observe(result string) {
+	if l.connections != nil {
+		l.connections.WithLabelValues(result).Inc()
+	}
+}
+
+// conn reports the source address from the PROXY header as the remote address.
+type conn struct {
+	net.Conn
+	listener *Listener
+
+	once   sync.Once
+	reader *bufio.Reader
+	remote net.Addr
+	err    error
+
+	mu sync.Mutex
+	// the read deadline set by the server, restored after the header is read
+	readDeadline time.Time
+}
+
+func (c *conn) init() {
+	c.once.Do(func() {
+		c.remote = c.Conn.RemoteAddr()
+
+		tcp, ok := c.remote.(*net.TCPAddr)
+		if !ok || !c.listener.cfg.isTrusted(tcp.AddrPort().Addr()) {
+			c.listener.observe(resultUntrusted)
+			return
+		}
+
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.listener.cfg.HeaderTimeout))
+
+		c.reader = bufio.NewReader(c.Conn)
+		src, result, err := readHeader(c.reader)
+		c.listener.observe(result)
+
+		c.mu.Lock()
+		_ = c.Conn.SetReadDeadline(c.readDeadline)
+		c.mu.Unlock()
+
+		if err != nil {
+			c.err = err
+			return
+		}
+
+		if src != nil {
+			c.remote = src
+		}
+	})
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.reader != nil {
+		return c.reader.Read(b)
+	}
+
+	return c.Conn.Read(b)
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	c.init()
+	return c.remote
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(t)
+}
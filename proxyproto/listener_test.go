@@ -0,0 +1,136 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counterValue(t *testing.T, c *prometheus.CounterVec, result string) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	require.NoError(t, c.WithLabelValues(result).Write(m))
+	return m.GetCounter().GetValue()
+}
+
+// accept sends the data from a new client connection and returns the accepted connection.
+func accept(t *testing.T, l net.Listener, data string) net.Conn {
+	t.Helper()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	_, err = client.Write([]byte(data))
+	require.NoError(t, err)
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func TestListener(t *testing.T) {
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = raw.Close() }()
+
+	cfg := &Config{Trusted: []string{"127.0.0.0/8", "::1"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.Equal(t, time.Second*5, cfg.HeaderTimeout)
+
+	connections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "connections"}, []string{"result"})
+	l := NewListener(raw, cfg, connections)
+
+	conn := accept(t, l, "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello")
+	assert.Equal(t, "192.0.2.1:56324", conn.RemoteAddr().String())
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+	assert.Equal(t, float64(1), counterValue(t, connections, resultV1))
+
+	// no header from the trusted source, the connection is served as is
+	conn = accept(t, l, "hello")
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+	assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+	assert.Equal(t, float64(1), counterValue(t, connections, resultNone))
+
+	// malformed header
+	conn = accept(t, l, "PROXY TCP4 garbage\r\n")
+	_, err = conn.Read(buf)
+	assert.Error(t, err)
+	assert.Equal(t, float64(1), counterValue(t, connections, resultError))
+}
+
+func TestListenerUntrusted(t *testing.T) {
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = raw.Close() }()
+
+	cfg := &Config{Trusted: []string{"10.0.0.0/8"}, HeaderTimeout: time.Second}
+	require.NoError(t, cfg.InitDefaults())
+
+	connections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "connections"}, []string{"result"})
+	l := NewListener(raw, cfg, connections)
+
+	// the header from an untrusted source is not decoded
+	header := "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"
+	conn := accept(t, l, header)
+	assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+
+	buf := make([]byte, len(header))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, header, string(buf))
+	assert.Equal(t, float64(1), counterValue(t, connections, resultUntrusted))
+}
+
+func TestListenerHeaderTimeout(t *testing.T) {
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = raw.Close() }()
+
+	cfg := &Config{Trusted: []string{"127.0.0.1"}, HeaderTimeout: time.Millisecond * 50}
+	require.NoError(t, cfg.InitDefaults())
+	l := NewListener(raw, cfg, nil)
+
+	// the header is never completed
+	conn := accept(t, l, "PROXY TCP4")
+	_, err = conn.Read(make([]byte, 1))
+	var ne net.Error
+	require.ErrorAs(t, err, &ne)
+	assert.True(t, ne.Timeout())
+}
+
+func TestConfig(t *testing.T) {
+	assert.Error(t, (&Config{}).InitDefaults())
+	assert.Error(t, (&Config{Trusted: []string{"10.0.0.0/33"}}).InitDefaults())
+	assert.Error(t, (&Config{Trusted: []string{"host"}}).InitDefaults())
+	assert.Error(t, (&Config{Trusted: []string{"10.0.0.1"}, HeaderTimeout: -1}).InitDefaults())
+
+	cfg := &Config{Trusted: []string{"10.1.2.3/8", "192.0.2.1", "2001:db8::/32"}}
+	require.NoError(t, cfg.InitDefaults())
+	assert.True(t, cfg.isTrusted(mustAddr(t, "10.200.0.1")))
+	assert.True(t, cfg.isTrusted(mustAddr(t, "::ffff:192.0.2.1")))
+	assert.True(t, cfg.isTrusted(mustAddr(t, "2001:db8::5")))
+	assert.False(t, cfg.isTrusted(mustAddr(t, "192.0.2.2")))
+}
+
+func mustAddr(t *testing.T, s string) netip.Addr {
+	t.Helper()
+	addr, err := netip.ParseAddr(s)
+	require.NoError(t, err)
+	return addr
+}
@@ -3,22 +3,27 @@ package grpc
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"os"
 	"path"
+	"slices"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/acl"
 	"github.com/roadrunner-server/grpc/v6/api"
+	"github.com/roadrunner-server/grpc/v6/certs"
+	"github.com/roadrunner-server/grpc/v6/operations"
+	"github.com/roadrunner-server/grpc/v6/orca"
 	"github.com/roadrunner-server/grpc/v6/parser"
 	"github.com/roadrunner-server/grpc/v6/proxy"
+	"github.com/roadrunner-server/pool/v2/fsm"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
@@ -35,6 +40,29 @@ func (p *Plugin) createGRPCserver(interceptors map[string]api.Interceptor) (*grp
 		p.interceptor,
 	}
 
+	// the streams rate of the calls not passing the tap handle
+	if p.limiter != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{p.limiter.UnaryServerInterceptor()}, unaryInterceptors...)
+		opts = append(opts, grpc.ChainStreamInterceptor(p.limiter.StreamServerInterceptor()))
+	}
+
+	var reporter *orca.Reporter
+	if p.config.Orca != nil {
+		reporter = orca.NewReporter(p.config.Orca, p.load)
+		unaryInterceptors = append(unaryInterceptors, reporter.UnaryServerInterceptor())
+
+		if p.config.Orca.Trailers {
+			opts = append(opts, reporter.ServerOption())
+		}
+	}
+
+	// the client addresses are checked before the configured interceptors and the workers
+	if p.config.ACL != nil {
+		checker := acl.NewChecker(p.config.ACL, p.log.With("component", "acl"))
+		unaryInterceptors = append(unaryInterceptors, checker.UnaryServerInterceptor())
+		opts = append(opts, grpc.ChainStreamInterceptor(checker.StreamServerInterceptor()))
+	}
+
 	// if we have interceptors in the config, we need to chain them with our interceptor, and add them to the server options
 	if len(p.config.Interceptors) > 0 {
 		// apply interceptors in the same order as they are configured
@@ -52,6 +80,8 @@ func (p *Plugin) createGRPCserver(interceptors map[string]api.Interceptor) (*grp
 		}
 	}
 
+	p.unaryInterceptors = unaryInterceptors
+
 	opts = append(
 		opts,
 		grpc.ChainUnaryInterceptor(
@@ -62,6 +92,51 @@ func (p *Plugin) createGRPCserver(interceptors map[string]api.Interceptor) (*grp
 	opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithTracerProvider(p.tracer), otelgrpc.WithPropagators(p.prop))))
 	server := grpc.NewServer(opts...)
 
+	// shared between all proxies
+	var idempotency *proxy.IdempotencyStore
+	if p.config.Idempotency != nil {
+		idempotency = proxy.NewIdempotencyStore(p.config.Idempotency)
+	}
+
+	var coalescer *proxy.Coalescer
+	if p.config.Coalescing != nil {
+		coalescer = proxy.NewCoalescer(p.config.Coalescing)
+	}
+
+	var cache *proxy.ResponseCache
+	if p.config.Cache != nil {
+		cache = proxy.NewResponseCache(p.config.Cache, p.cacheRequests)
+	}
+
+	var batcher *proxy.Batcher
+	if p.config.Batching != nil {
+		batcher = proxy.NewBatcher(p.config.Batching)
+	}
+
+	var hedger *proxy.Hedger
+	if p.config.Hedging != nil {
+		hedger = proxy.NewHedger(p.config.Hedging)
+	}
+
+	if p.config.Async != nil {
+		p.async, err = proxy.NewAsyncDispatcher(p.config.Async, p.log.With("component", "async"))
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	if reporter != nil && p.config.Orca.OutOfBand {
+		err = orca.Register(server, reporter)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	if p.config.LongRunning != nil {
+		p.operations = operations.NewStore(p.config.LongRunning.Retention, p.config.LongRunning.MaxOperations, p.log.With("component", "operations"))
+		operations.Register(server, p.operations)
+	}
+
 	for i := range p.config.Proto {
 		if p.config.Proto[i] == "" {
 			continue
@@ -74,9 +149,35 @@ func (p *Plugin) createGRPCserver(interceptors map[string]api.Interceptor) (*grp
 		}
 
 		for _, service := range services {
-			px := proxy.NewProxy(fmt.Sprintf("%s.%s", service.Package, service.Name), p.config.Proto[i], p.log.With("service", service.Name), p.gPool, p.mu, p.prop)
+			name := fmt.Sprintf("%s.%s", service.Package, service.Name)
+			px := proxy.NewProxy(name, p.config.Proto[i], p.log.With("service", service.Name), p.gPool, p.mu, p.prop)
+
+			var coalesced, hedged []string
 			for _, m := range service.Methods {
 				px.RegisterMethod(m.Name)
+
+				if p.config.Coalescing != nil && (m.NoSideEffects || slices.Contains(p.config.Coalescing.Methods, name+"/"+m.Name)) {
+					coalesced = append(coalesced, m.Name)
+				}
+
+				if p.config.Hedging != nil && (m.NoSideEffects || slices.Contains(p.config.Hedging.Methods, name+"/"+m.Name)) {
+					hedged = append(hedged, m.Name)
+				}
+			}
+
+			px.SetFaultInjection(p.config.FaultInjection)
+			px.SetIdempotency(idempotency)
+			px.SetCoalescing(coalescer, coalesced)
+			px.SetCache(cache)
+			px.SetAsync(p.async)
+			px.SetBatching(batcher)
+			px.SetHedging(hedger, hedged)
+			px.SetOperations(p.operations, p.config.LongRunning)
+			px.SetIdentity(p.config.ClientIdentity)
+			px.SetSizeLimits(p.config.SizeLimits, p.sizeLimitRejections, p.requestCounter)
+
+			if p.config.Compression != nil {
+				px.SetCompression(p.config.Compression.Rules)
 			}
 
 			server.RegisterService(px.ServiceDesc(), px)
@@ -91,6 +192,7 @@ func (p *Plugin) interceptor(ctx context.Context, req any, info *grpc.UnaryServe
 	start := time.Now()
 
 	p.queueSize.Inc()
+	p.inFlight.Add(1)
 
 	resp, err := handler(ctx, req)
 
@@ -107,8 +209,9 @@ func (p *Plugin) interceptor(ctx context.Context, req any, info *grpc.UnaryServe
 
 	defer func() {
 		p.requestCounter.WithLabelValues(info.FullMethod, statusCode.String()).Inc()
-		p.requestDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+		p.observeDuration(ctx, info.FullMethod, time.Since(start))
 		p.queueSize.Dec()
+		p.inFlight.Add(-1)
 	}()
 
 	if err != nil {
@@ -125,6 +228,40 @@ func (p *Plugin) interceptor(ctx context.Context, req any, info *grpc.UnaryServe
 	return resp, nil
 }
 
+// load returns the workers pool load reported to the ORCA clients, the queued calls are the in-flight calls
+// without a worker.
+func (p *Plugin) load() orca.Load {
+	workers := p.Workers()
+
+	var busy int
+	for _, w := range workers {
+		if w.Status == fsm.StateWorking {
+			busy++
+		}
+	}
+
+	return orca.Load{
+		Workers: len(workers),
+		Busy:    busy,
+		Queued:  max(0, int(p.inFlight.Load())-busy),
+	}
+}
+
+// observeDuration records the call duration, the original client address is attached as an exemplar when the
+// addresses are taken from the PROXY protocol headers.
+func (p *Plugin) observeDuration(ctx context.Context, method string, elapsed time.Duration) {
+	obs := p.requestDuration.WithLabelValues(method)
+
+	if pr, ok := peer.FromContext(ctx); ok && p.config.ProxyProtocol != nil {
+		if eo, ok := obs.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(elapsed.Seconds(), prometheus.Labels{"peer": pr.Addr.String()})
+			return
+		}
+	}
+
+	obs.Observe(elapsed.Seconds())
+}
+
 // statusDetails renders the well-known google.rpc.* error details attached to s as
 // compact, one-line strings for logging, skipping any other (and potentially large)
 // handler-attached detail. It returns nil when there are no such details.
@@ -159,60 +296,16 @@ func statusDetails(s *status.Status) []string {
 }
 
 func (p *Plugin) serverOptions() ([]grpc.ServerOption, error) {
-	const op = errors.Op("grpc_plugin_server_options")
-
-	var tcreds credentials.TransportCredentials
 	var opts []grpc.ServerOption
-	var cert tls.Certificate
-	var certPool *x509.CertPool
-	var rca []byte
-	var err error
-
-	if p.config.EnableTLS() {
-		// if client CA is not empty, we combine it with Cert and Key
-		if p.config.TLS.RootCA != "" {
-			cert, err = tls.LoadX509KeyPair(p.config.TLS.Cert, p.config.TLS.Key)
-			if err != nil {
-				return nil, err
-			}
-
-			certPool, err = x509.SystemCertPool()
-			if err != nil {
-				return nil, err
-			}
-			if certPool == nil {
-				certPool = x509.NewCertPool()
-			}
-
-			rca, err = os.ReadFile(p.config.TLS.RootCA)
-			if err != nil {
-				return nil, err
-			}
-
-			if ok := certPool.AppendCertsFromPEM(rca); !ok {
-				return nil, errors.E(op, errors.Str("could not append Certs from PEM"))
-			}
-
-			opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
-				MinVersion:   tls.VersionTLS12,
-				ClientAuth:   p.config.TLS.auth,
-				Certificates: []tls.Certificate{cert},
-				ClientCAs:    certPool,
-			})))
-		} else {
-			// regular TLS from the cert+key
-			tcreds, err = credentials.NewServerTLSFromFile(p.config.TLS.Cert, p.config.TLS.Key)
-			if err != nil {
-				return nil, err
-			}
 
-			opts = append(opts, grpc.Creds(tcreds))
-		}
+	// the TLS handshake is made with the credentials of the listener accepted the connection
+	if p.config.anyTLS() {
+		opts = append(opts, grpc.Creds(newListenerCredentials(p.config.listeners())))
 	}
 
 	serverOptions := []grpc.ServerOption{
 		grpc.MaxSendMsgSize(int(p.config.MaxSendMsgSize)),
-		grpc.MaxRecvMsgSize(int(p.config.MaxRecvMsgSize)),
+		grpc.MaxRecvMsgSize(int(p.config.recvLimit())),
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			MaxConnectionIdle:     p.config.MaxConnectionIdle,
 			MaxConnectionAge:      p.config.MaxConnectionAge,
@@ -224,8 +317,47 @@ func (p *Plugin) serverOptions() ([]grpc.ServerOption, error) {
 	}
 
 	opts = append(opts, serverOptions...)
+
+	// the requests over the decompression bound never reach the proxies
+	if p.config.SizeLimits != nil {
+		opts = append(opts, grpc.StatsHandler(&sizeLimitStats{rejections: p.sizeLimitRejections, requests: p.requestCounter}))
+	}
+
+	// the streams are rejected before the request is read
+	if p.limiter != nil {
+		opts = append(opts, grpc.InTapHandle(p.limiter.TapHandle))
+	}
+
 	opts = append(opts, p.opts...)
 
 	// custom codec is required to bypass protobuf, a common interceptor used for debug and stats
 	return opts, nil
 }
+
+// tlsConfig returns the server TLS configuration, nil when TLS is disabled. The certificate is selected by SNI,
+// the files are watched for changes when it is configured.
+func (p *Plugin) tlsConfig(cfg *TLS) (*tls.Config, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+
+	r, err := certs.NewReloader(certs.Files{
+		Certificates: cfg.keyPairs(),
+		RootCA:       cfg.RootCA,
+		ClientAuth:   cfg.auth,
+	}, p.log, p.certExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Watch || cfg.ReloadInterval > 0 {
+		err = r.Watch(cfg.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.certReloaders = append(p.certReloaders, r)
+
+	return r.TLSConfig(), nil
+}
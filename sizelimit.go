@@ -0,0 +1,56 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// decompressionRejected is the part of the status message the gRPC server rejects the decompressed message over
+// max_recv_msg_size with. The compressed message over the limit and the RESOURCE_EXHAUSTED returned by the handlers
+// are sent with other messages.
+const decompressionRejected string = "after decompression larger than max"
+
+// sizeLimitStats counts the requests rejected by the gRPC server itself, before the message reached the handler:
+// the compressed requests within max_recv_msg_size lowered to the largest of the size limits, but over it once
+// decompressed. The requests rejected by the method limits are counted by the proxies.
+type sizeLimitStats struct {
+	rejections *prometheus.CounterVec
+	requests   *prometheus.CounterVec
+}
+
+type sizeLimitKey struct{}
+
+func (s *sizeLimitStats) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, sizeLimitKey{}, info.FullMethodName)
+}
+
+func (s *sizeLimitStats) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	end, ok := rs.(*stats.End)
+	if !ok {
+		return
+	}
+
+	method, ok := ctx.Value(sizeLimitKey{}).(string)
+	if !ok {
+		return
+	}
+
+	st := status.Convert(end.Error)
+	if st.Code() != codes.ResourceExhausted || !strings.Contains(st.Message(), decompressionRejected) {
+		return
+	}
+
+	s.rejections.WithLabelValues(method).Inc()
+	s.requests.WithLabelValues(method, codes.ResourceExhausted.String()).Inc()
+}
+
+func (s *sizeLimitStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (s *sizeLimitStats) HandleConn(context.Context, stats.ConnStats) {}
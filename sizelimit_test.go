@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/roadrunner-server/grpc/v6/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecvLimit(t *testing.T) {
+	c := &Config{MaxRecvMsgSize: 1024}
+	assert.Equal(t, int64(1024), c.recvLimit())
+
+	c.SizeLimits = &proxy.SizeLimitConfig{MaxBytes: 64, Methods: []*proxy.MethodSizeLimit{{Method: "app.Service/Upload", MaxBytes: 128}}}
+	assert.Equal(t, int64(128), c.recvLimit())
+
+	c.SizeLimits.MaxBytes = 4096
+	assert.Equal(t, int64(1024), c.recvLimit())
+
+	// an unlimited method
+	c.SizeLimits = &proxy.SizeLimitConfig{MaxBytes: 64, Methods: []*proxy.MethodSizeLimit{{Method: "app.Service/Import"}}}
+	assert.Equal(t, int64(1024), c.recvLimit())
+}
+
+func TestSizeLimitStats(t *testing.T) {
+	rejections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "r"}, []string{"grpc_method"})
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "q"}, []string{"grpc_method", "status_code"})
+
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(64),
+		grpc.StatsHandler(&sizeLimitStats{rejections: rejections, requests: requests}),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if req.(*grpc_health_v1.HealthCheckRequest).GetService() == "busy" {
+				return nil, status.Error(codes.ResourceExhausted, "queue is full")
+			}
+			return handler(ctx, req)
+		}),
+	)
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := grpc_health_v1.NewHealthClient(conn)
+	const method = "/grpc.health.v1.Health/Check"
+
+	// the message over the limit on the wire is not counted
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: strings.Repeat("a", 4096)})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// the received requests are not counted, whatever the status
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "busy"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// the compressed message is small, the decompressed one is not
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: strings.Repeat("a", 4096)}, grpc.UseCompressor(gzip.Name))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// the call is ended after the status is sent
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(requests.WithLabelValues(method, "ResourceExhausted")) == 1
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, float64(1), testutil.ToFloat64(rejections.WithLabelValues(method)))
+	assert.Equal(t, 1, testutil.CollectAndCount(requests))
+}
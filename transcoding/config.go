@@ -0,0 +1,29 @@
+// Package transcoding serves the REST routes defined by the google.api.http annotations of the proxied methods.
+// JSON requests are converted to protobuf using the descriptors of the protoreg plugin.
+package transcoding
+
+import (
+	"github.com/roadrunner-server/errors"
+)
+
+// Config configures the HTTP/JSON transcoding listener.
+type Config struct {
+	// Address is the HTTP listener address, e.g. `127.0.0.1:8080`.
+	Address string `mapstructure:"address"`
+	// EmitUnpopulated renders the fields with the zero values in the responses.
+	EmitUnpopulated bool `mapstructure:"emit_unpopulated"`
+	// UseProtoNames renders the original proto field names instead of the lowerCamelCase JSON names.
+	UseProtoNames bool `mapstructure:"use_proto_names"`
+	// DiscardUnknown ignores the unknown fields in the request bodies and query parameters.
+	DiscardUnknown bool `mapstructure:"discard_unknown"`
+}
+
+func (c *Config) InitDefaults() error {
+	const op = errors.Op("grpc_transcoding_init")
+
+	if c.Address == "" {
+		return errors.E(op, errors.Str("address is required for the HTTP/JSON transcoding"))
+	}
+
+	return nil
+}
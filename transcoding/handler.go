@@ -0,0 +1,517 @@
+package transcoding
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	stderr "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jhump/protoreflect/v2/protoresolve"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/grpc/v6/api"
+	"github.com/roadrunner-server/grpc/v6/internal/gateway"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	contentTypeJSON string = "application/json"
+
+	// prefixes of the response metadata, the same as grpc-gateway uses
+	metadataHeaderPrefix  string = "Grpc-Metadata-"
+	metadataTrailerPrefix string = "Grpc-Trailer-"
+)
+
+// route is an HTTP binding of a method.
+type route struct {
+	*rule
+	template   *template
+	fullMethod string
+	md         protoreflect.MethodDescriptor
+}
+
+// Handler serves the HTTP bindings of the methods by calling them through the invoker.
+type Handler struct {
+	log     *slog.Logger
+	invoker api.Invoker
+	routes  []*route
+	maxBody int64
+
+	marshal   protojson.MarshalOptions
+	unmarshal protojson.UnmarshalOptions
+	binary    proto.UnmarshalOptions
+}
+
+// NewHandler creates the routes of the google.api.http bindings of the given services (package.Service), the
+// descriptors are resolved by the registry. The request bodies are limited to maxBody bytes.
+func NewHandler(cfg *Config, registry *protoresolve.Registry, services []string, invoker api.Invoker, maxBody int64, log *slog.Logger) (*Handler, error) {
+	const op = errors.Op("grpc_transcoding_handler")
+
+	types := registry.AsTypeResolver()
+	h := &Handler{
+		log:     log,
+		invoker: invoker,
+		maxBody: maxBody,
+		marshal: protojson.MarshalOptions{
+			EmitUnpopulated: cfg.EmitUnpopulated,
+			UseProtoNames:   cfg.UseProtoNames,
+			Resolver:        types,
+		},
+		unmarshal: protojson.UnmarshalOptions{
+			DiscardUnknown: cfg.DiscardUnknown,
+			Resolver:       types,
+		},
+		binary: proto.UnmarshalOptions{
+			Resolver: types,
+		},
+	}
+
+	for _, name := range services {
+		d, err := registry.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			log.Warn("service descriptor was not found, HTTP bindings are skipped", "service", name, "error", err)
+			continue
+		}
+
+		sd, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, errors.E(op, errors.Errorf("%s is not a service", name))
+		}
+
+		for i := range sd.Methods().Len() {
+			md := sd.Methods().Get(i)
+
+			rules, err := methodRules(md)
+			if err != nil {
+				return nil, errors.E(op, err)
+			}
+
+			if len(rules) > 0 && (md.IsStreamingClient() || md.IsStreamingServer()) {
+				log.Warn("streaming methods are not supported, HTTP bindings are skipped", "method", md.FullName())
+				continue
+			}
+
+			for _, r := range rules {
+				rt, err := newRoute(md, r)
+				if err != nil {
+					return nil, errors.E(op, err)
+				}
+
+				log.Debug("http route was registered", "method", rt.fullMethod, "http_method", r.method, "path", r.path)
+				h.routes = append(h.routes, rt)
+			}
+		}
+	}
+
+	return h, nil
+}
+
+func newRoute(md protoreflect.MethodDescriptor, r *rule) (*route, error) {
+	tmpl, err := parseTemplate(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	input := md.Input()
+	for _, v := range tmpl.variables {
+		if _, err = fieldByPath(input, v.field); err != nil {
+			return nil, errors.Errorf("%s: %v", md.FullName(), err)
+		}
+	}
+
+	if r.body != "" && r.body != "*" {
+		if _, err = fieldByPath(input, []string{r.body}); err != nil {
+			return nil, errors.Errorf("%s body: %v", md.FullName(), err)
+		}
+	}
+
+	if r.responseBody != "" {
+		if _, err = fieldByPath(md.Output(), []string{r.responseBody}); err != nil {
+			return nil, errors.Errorf("%s response_body: %v", md.FullName(), err)
+		}
+	}
+
+	return &route{
+		rule:       r,
+		template:   tmpl,
+		fullMethod: "/" + string(md.Parent().FullName()) + "/" + string(md.Name()),
+		md:         md,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+
+	allowed := false
+	for _, rt := range h.routes {
+		values, ok := rt.template.match(path)
+		if !ok {
+			continue
+		}
+
+		if rt.method != r.Method {
+			allowed = true
+			continue
+		}
+
+		h.serve(w, r, rt, values)
+		return
+	}
+
+	if allowed {
+		h.writeStatus(w, http.StatusMethodNotAllowed, status.New(codes.Unimplemented, "method not allowed"))
+		return
+	}
+
+	h.writeStatus(w, http.StatusNotFound, status.New(codes.NotFound, "route not found"))
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, rt *route, values map[string]string) {
+	in, err := h.request(w, r, rt, values)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	data, err := proto.Marshal(in)
+	if err != nil {
+		h.writeError(w, status.Error(codes.Internal, err.Error()))
+		return
+	}
+
+	md, err := gateway.Metadata(r.Header, metadataKey)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: gateway.Addr(r.RemoteAddr)})
+
+	out, header, trailer, err := h.invoker.Invoke(ctx, rt.fullMethod, data)
+
+	for k, v := range header {
+		for _, vv := range v {
+			w.Header().Add(metadataHeaderPrefix+k, vv)
+		}
+	}
+
+	for k, v := range trailer {
+		for _, vv := range v {
+			w.Header().Add(metadataTrailerPrefix+k, vv)
+		}
+	}
+
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	body, err := h.response(rt, out)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// request builds the request message from the path variables, the body and the query parameters.
+func (h *Handler) request(w http.ResponseWriter, r *http.Request, rt *route, values map[string]string) (*dynamicpb.Message, error) {
+	in := dynamicpb.NewMessage(rt.md.Input())
+
+	if rt.body != "" {
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBody))
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if stderr.As(err, &mbe) {
+				return nil, status.Errorf(codes.ResourceExhausted, "request body is larger than %d bytes", h.maxBody)
+			}
+
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		if len(data) > 0 {
+			err = h.body(in, rt.body, data)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "malformed request body: %v", err)
+			}
+		}
+	}
+
+	for field, value := range values {
+		err := setField(in, strings.Split(field, "."), []string{value})
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "path parameter %s: %v", field, err)
+		}
+	}
+
+	// the whole message is the body
+	if rt.body == "*" {
+		return in, nil
+	}
+
+	for key, vals := range r.URL.Query() {
+		if _, ok := values[key]; ok {
+			continue
+		}
+
+		path := strings.Split(key, ".")
+		if rt.body != "" && path[0] == rt.body {
+			continue
+		}
+
+		err := setField(in, path, vals)
+		if err != nil {
+			if h.unmarshal.DiscardUnknown && stderr.Is(err, errUnknownField) {
+				continue
+			}
+
+			return nil, status.Errorf(codes.InvalidArgument, "query parameter %s: %v", key, err)
+		}
+	}
+
+	return in, nil
+}
+
+// body decodes the JSON body into the message or into its field.
+func (h *Handler) body(in *dynamicpb.Message, field string, data []byte) error {
+	if field == "*" {
+		return h.unmarshal.Unmarshal(data, in)
+	}
+
+	fd, err := fieldByPath(in.Descriptor(), []string{field})
+	if err != nil {
+		return err
+	}
+
+	// the field of any type is decoded as a member of the parent message
+	wrapper := make([]byte, 0, len(data)+len(fd.JSONName())+5)
+	wrapper = append(wrapper, '{')
+	wrapper = strconv.AppendQuote(wrapper, fd.JSONName())
+	wrapper = append(wrapper, ':')
+	wrapper = append(wrapper, data...)
+	wrapper = append(wrapper, '}')
+
+	tmp := dynamicpb.NewMessage(in.Descriptor())
+	err = h.unmarshal.Unmarshal(wrapper, tmp)
+	if err != nil {
+		return err
+	}
+
+	in.Set(fd, tmp.Get(fd))
+	return nil
+}
+
+// response renders the response message or its response_body field.
+func (h *Handler) response(rt *route, out []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(rt.md.Output())
+	err := h.binary.Unmarshal(out, msg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "malformed worker response: %v", err)
+	}
+
+	if rt.responseBody == "" {
+		return h.marshal.Marshal(msg)
+	}
+
+	fd, err := fieldByPath(msg.Descriptor(), []string{rt.responseBody})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+		return h.marshal.Marshal(msg.Get(fd).Message().Interface())
+	}
+
+	// the field is always rendered, even with the zero value
+	opts := h.marshal
+	opts.EmitUnpopulated = true
+	data, err := opts.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fd.JSONName()
+	if h.marshal.UseProtoNames {
+		key = string(fd.Name())
+	}
+
+	return fields[key], nil
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	h.writeStatus(w, gateway.HTTPStatus(st.Code()), st)
+}
+
+// writeStatus renders the status in the google.rpc.Status JSON form.
+func (h *Handler) writeStatus(w http.ResponseWriter, code int, st *status.Status) {
+	data, err := h.marshal.Marshal(st.Proto())
+	if err != nil {
+		// the details types are not in the registry
+		data, err = protojson.Marshal(st.Proto())
+		if err != nil {
+			h.log.Warn("failed to render the status details", "error", err)
+			data, _ = protojson.Marshal(status.New(st.Code(), st.Message()).Proto())
+		}
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+var errUnknownField = stderr.New("unknown field") //nolint:gochecknoglobals
+
+// fieldByPath resolves the field by its proto or JSON name path.
+func fieldByPath(md protoreflect.MessageDescriptor, path []string) (protoreflect.FieldDescriptor, error) {
+	var fd protoreflect.FieldDescriptor
+	for i, name := range path {
+		if i > 0 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return nil, errors.Errorf("%s is not a singular message field", fd.Name())
+			}
+			md = fd.Message()
+		}
+
+		fd = md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = md.Fields().ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("%w %s in %s", errUnknownField, name, md.FullName())
+		}
+	}
+
+	return fd, nil
+}
+
+// setField sets the field by its path, the intermediate messages are created.
+func setField(msg protoreflect.Message, path []string, values []string) error {
+	fd, err := fieldByPath(msg.Descriptor(), path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range path[:len(path)-1] {
+		parent := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if parent == nil {
+			parent = msg.Descriptor().Fields().ByJSONName(name)
+		}
+		msg = msg.Mutable(parent).Message()
+	}
+
+	switch {
+	case fd.IsMap():
+		return errors.Str("map fields are not supported")
+	case fd.IsList():
+		list := msg.Mutable(fd).List()
+		for _, s := range values {
+			v, errP := parseValue(fd, s, list.NewElement)
+			if errP != nil {
+				return errP
+			}
+			list.Append(v)
+		}
+
+		return nil
+	default:
+		if len(values) == 0 {
+			return nil
+		}
+
+		v, errP := parseValue(fd, values[len(values)-1], func() protoreflect.Value { return msg.NewField(fd) })
+		if errP != nil {
+			return errP
+		}
+		msg.Set(fd, v)
+
+		return nil
+	}
+}
+
+// parseValue parses the path or query parameter, the messages (well-known types) are parsed from their JSON form.
+func parseValue(fd protoreflect.FieldDescriptor, s string, newValue func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		v, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			v, err = base64.URLEncoding.DecodeString(s)
+		}
+		return protoreflect.ValueOfBytes(v), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+
+		v, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, errors.Errorf("unknown value %s of the enum %s", s, fd.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v := newValue()
+		err := protojson.Unmarshal([]byte(strconv.Quote(s)), v.Message().Interface())
+		return v, err
+	default:
+		return protoreflect.Value{}, errors.Errorf("unsupported field kind %s", fd.Kind())
+	}
+}
+
+// metadataKey maps the request header to the metadata key the way grpc-gateway does: the `Grpc-Metadata-` prefixed
+// headers are forwarded without the prefix, Authorization and User-Agent as is, the other headers are skipped.
+func metadataKey(header string) string {
+	if k, ok := strings.CutPrefix(header, metadataHeaderPrefix); ok {
+		return k
+	}
+
+	switch header {
+	case "Authorization", "User-Agent":
+		return header
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,328 @@
+package transcoding
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/v2/protoresolve"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// fakeInvoker decodes the request and responds with the message built by respond.
+type fakeInvoker struct {
+	registry *protoresolve.Registry
+	method   string
+	request  *dynamicpb.Message
+	md       metadata.MD
+	respond  func(in *dynamicpb.Message) (proto.Message, error)
+}
+
+func (f *fakeInvoker) Invoke(ctx context.Context, method string, in []byte) ([]byte, metadata.MD, metadata.MD, error) {
+	f.method = method
+	f.md, _ = metadata.FromIncomingContext(ctx)
+
+	d, err := f.registry.FindDescriptorByName(protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", ".")))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	f.request = dynamicpb.NewMessage(d.(protoreflect.MethodDescriptor).Input())
+	if err = proto.Unmarshal(in, f.request); err != nil {
+		return nil, nil, nil, err
+	}
+
+	resp, err := f.respond(f.request)
+	if err != nil {
+		return nil, metadata.Pairs("x-header", "h"), nil, err
+	}
+
+	out, err := proto.Marshal(resp)
+	return out, metadata.Pairs("x-header", "h"), metadata.Pairs("x-trailer", "t"), err
+}
+
+// httpOption encodes the google.api.http option with the given HttpRule fields.
+func httpOption(fields map[protowire.Number]string, additional ...map[protowire.Number]string) *descriptorpb.MethodOptions {
+	encode := func(fields map[protowire.Number]string) []byte {
+		var b []byte
+		for num, v := range fields {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		}
+		return b
+	}
+
+	rule := encode(fields)
+	for _, a := range additional {
+		rule = protowire.AppendTag(rule, ruleAdditionalBindings, protowire.BytesType)
+		rule = protowire.AppendBytes(rule, encode(a))
+	}
+
+	opts := &descriptorpb.MethodOptions{}
+	opts.ProtoReflect().SetUnknown(protowire.AppendBytes(protowire.AppendTag(nil, httpRuleExtension, protowire.BytesType), rule))
+	return opts
+}
+
+func field(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(num),
+		Type:     typ.Enum(),
+		Label:    label.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	if name == "page_count" {
+		f.JsonName = proto.String("pageCount")
+	}
+	return f
+}
+
+func newTestRegistry(t *testing.T) *protoresolve.Registry {
+	t.Helper()
+
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i32 = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		msg = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+		enm = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+	)
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("shelf.proto"),
+		Package: proto.String("app"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Kind"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("PAPER"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Book"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, str, "", false),
+				field("page_count", 2, i32, "", false),
+				field("tags", 3, str, "", true),
+				field("kind", 4, enm, ".app.Kind", false),
+			}},
+			{Name: proto.String("Options"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("limit", 1, i32, "", false),
+			}},
+			{Name: proto.String("GetBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, str, "", false),
+				field("options", 2, msg, ".app.Options", false),
+			}},
+			{Name: proto.String("CreateBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("parent", 1, str, "", false),
+				field("book", 2, msg, ".app.Book", false),
+			}},
+			{Name: proto.String("ListBooksResponse"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("books", 1, msg, ".app.Book", true),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Shelf"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{
+					Name: proto.String("GetBook"), InputType: proto.String(".app.GetBookRequest"), OutputType: proto.String(".app.Book"),
+					Options: httpOption(map[protowire.Number]string{ruleGet: "/v1/{name=shelves/*/books/*}"}),
+				},
+				{
+					Name: proto.String("CreateBook"), InputType: proto.String(".app.CreateBookRequest"), OutputType: proto.String(".app.Book"),
+					Options: httpOption(map[protowire.Number]string{rulePost: "/v1/{parent=shelves/*}/books", ruleBody: "book"}),
+				},
+				{
+					Name: proto.String("UpdateBook"), InputType: proto.String(".app.Book"), OutputType: proto.String(".app.Book"),
+					Options: httpOption(
+						map[protowire.Number]string{rulePatch: "/v1/books/{name}", ruleBody: "*"},
+						map[protowire.Number]string{rulePut: "/v2/books/{name}", ruleBody: "*"},
+					),
+				},
+				{
+					Name: proto.String("ListBooks"), InputType: proto.String(".app.GetBookRequest"), OutputType: proto.String(".app.ListBooksResponse"),
+					Options: httpOption(map[protowire.Number]string{ruleGet: "/v1/books", ruleResponseBody: "books"}),
+				},
+				{
+					Name: proto.String("NoBinding"), InputType: proto.String(".app.Book"), OutputType: proto.String(".app.Book"),
+				},
+			},
+		}},
+	}
+
+	reg := &protoresolve.Registry{}
+	_, err := reg.RegisterFileProto(fdp)
+	require.NoError(t, err)
+
+	return reg
+}
+
+func newTestHandler(t *testing.T, cfg *Config, inv *fakeInvoker) *Handler {
+	t.Helper()
+	h, err := NewHandler(cfg, inv.registry, []string{"app.Shelf", "app.Missing"}, inv, 1024, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	return h
+}
+
+// book builds an app.Book from its JSON form.
+func book(t *testing.T, reg *protoresolve.Registry, js string) *dynamicpb.Message {
+	t.Helper()
+	md, err := reg.FindMessageByName("app.Book")
+	require.NoError(t, err)
+
+	m := dynamicpb.NewMessage(md)
+	require.NoError(t, protojson.Unmarshal([]byte(js), m))
+	return m
+}
+
+func TestRoutes(t *testing.T) {
+	inv := &fakeInvoker{registry: newTestRegistry(t)}
+	h := newTestHandler(t, &Config{Address: "127.0.0.1:0"}, inv)
+
+	// the additional bindings are registered too, the methods without bindings are skipped
+	require.Len(t, h.routes, 5)
+	assert.Equal(t, "/app.Shelf/GetBook", h.routes[0].fullMethod)
+	assert.Equal(t, http.MethodPut, h.routes[3].method)
+}
+
+func TestTranscodeGet(t *testing.T) {
+	reg := newTestRegistry(t)
+	inv := &fakeInvoker{registry: reg}
+	inv.respond = func(in *dynamicpb.Message) (proto.Message, error) {
+		return book(t, reg, `{"name":"shelves/1/books/2","pageCount":10,"tags":["a"],"kind":"PAPER"}`), nil
+	}
+	h := newTestHandler(t, &Config{Address: "127.0.0.1:0"}, inv)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/shelves/1/books/2?options.limit=5", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("Grpc-Metadata-Tenant", "acme")
+	r.Header.Set("Grpc-Metadata-Grpc-Timeout", "1S")
+	r.Header.Set("Cookie", "session=1")
+	r.Header.Set("X-Custom", "1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "/app.Shelf/GetBook", inv.method)
+	assert.JSONEq(t, `{"name":"shelves/1/books/2","options":{"limit":5}}`, protojson.Format(inv.request))
+	assert.Equal(t, []string{"Bearer token"}, inv.md.Get("authorization"))
+	// only the allowed and the prefixed headers are forwarded, without the reserved keys
+	assert.Equal(t, []string{"acme"}, inv.md.Get("tenant"))
+	assert.Empty(t, inv.md.Get("grpc-timeout"))
+	assert.Empty(t, inv.md.Get("cookie"))
+	assert.Empty(t, inv.md.Get("x-custom"))
+
+	assert.Equal(t, contentTypeJSON, w.Header().Get("Content-Type"))
+	assert.Equal(t, "h", w.Header().Get("Grpc-Metadata-X-Header"))
+	assert.Equal(t, "t", w.Header().Get("Grpc-Trailer-X-Trailer"))
+	assert.JSONEq(t, `{"name":"shelves/1/books/2","pageCount":10,"tags":["a"],"kind":"PAPER"}`, w.Body.String())
+
+	// unknown query parameters are rejected
+	r = httptest.NewRequest(http.MethodGet, "/v1/shelves/1/books/2?unknown=1", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTranscodeBody(t *testing.T) {
+	reg := newTestRegistry(t)
+	inv := &fakeInvoker{registry: reg}
+	inv.respond = func(in *dynamicpb.Message) (proto.Message, error) {
+		return book(t, reg, `{"name":"created"}`), nil
+	}
+	h := newTestHandler(t, &Config{Address: "127.0.0.1:0", UseProtoNames: true}, inv)
+
+	// the body is the book field, the parent is bound from the path
+	r := httptest.NewRequest(http.MethodPost, "/v1/shelves/1/books", strings.NewReader(`{"name":"b","page_count":3}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"parent":"shelves/1","book":{"name":"b","pageCount":3}}`, protojson.Format(inv.request))
+
+	// the body is the whole message, the path variable overrides it
+	r = httptest.NewRequest(http.MethodPut, "/v2/books/x", strings.NewReader(`{"name":"y","tags":["a","b"]}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "/app.Shelf/UpdateBook", inv.method)
+	assert.JSONEq(t, `{"name":"x","tags":["a","b"]}`, protojson.Format(inv.request))
+
+	r = httptest.NewRequest(http.MethodPatch, "/v1/books/x", strings.NewReader(`{"name":`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	r = httptest.NewRequest(http.MethodPatch, "/v1/books/x", strings.NewReader(`{"name":"`+strings.Repeat("a", 2048)+`"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestTranscodeResponseBody(t *testing.T) {
+	reg := newTestRegistry(t)
+	inv := &fakeInvoker{registry: reg}
+	inv.respond = func(in *dynamicpb.Message) (proto.Message, error) {
+		return dynamicpb.NewMessage(in.Descriptor().ParentFile().Messages().ByName("ListBooksResponse")), nil
+	}
+	h := newTestHandler(t, &Config{Address: "127.0.0.1:0"}, inv)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/books", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
+
+func TestTranscodeErrors(t *testing.T) {
+	reg := newTestRegistry(t)
+	inv := &fakeInvoker{registry: reg}
+	inv.respond = func(*dynamicpb.Message) (proto.Message, error) {
+		return nil, status.Error(codes.NotFound, "no such book")
+	}
+	h := newTestHandler(t, &Config{Address: "127.0.0.1:0"}, inv)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/shelves/1/books/2", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var st map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &st))
+	assert.Equal(t, float64(codes.NotFound), st["code"])
+	assert.Equal(t, "no such book", st["message"])
+
+	r = httptest.NewRequest(http.MethodDelete, "/v1/shelves/1/books/2", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/unknown", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"code":5,"message":"route not found"}`, w.Body.String())
+}
@@ -0,0 +1,172 @@
+package transcoding
+
+import (
+	"net/http"
+
+	"github.com/roadrunner-server/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// the google.api.http option is read from the raw method options, so the google.api annotations are not
+// required as a dependency, field numbers are taken from google/api/http.proto and google/api/annotations.proto
+const (
+	// google.api.http extension of google.protobuf.MethodOptions
+	httpRuleExtension protowire.Number = 72295728
+
+	// HttpRule
+	ruleGet                protowire.Number = 2
+	rulePut                protowire.Number = 3
+	rulePost               protowire.Number = 4
+	ruleDelete             protowire.Number = 5
+	rulePatch              protowire.Number = 6
+	ruleBody               protowire.Number = 7
+	ruleCustom             protowire.Number = 8
+	ruleAdditionalBindings protowire.Number = 11
+	ruleResponseBody       protowire.Number = 12
+
+	// CustomHttpPattern
+	customKind protowire.Number = 1
+	customPath protowire.Number = 2
+)
+
+// rule is a single HTTP binding of a method.
+type rule struct {
+	method       string
+	path         string
+	body         string
+	responseBody string
+}
+
+// methodRules returns the HTTP bindings of the method, including the additional ones.
+func methodRules(md protoreflect.MethodDescriptor) ([]*rule, error) {
+	opts := md.Options()
+	if opts == nil {
+		return nil, nil
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*rule
+	err = walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != httpRuleExtension || typ != protowire.BytesType {
+			return protowire.ConsumeFieldValue(num, typ, b), nil
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+
+		parsed, errP := parseRule(v, true)
+		if errP != nil {
+			return 0, errP
+		}
+		rules = append(rules, parsed...)
+
+		return n, nil
+	})
+	if err != nil {
+		return nil, errors.Errorf("malformed google.api.http option of %s: %v", md.FullName(), err)
+	}
+
+	return rules, nil
+}
+
+// parseRule parses the HttpRule message, the additional bindings are parsed only on the top level.
+func parseRule(data []byte, top bool) ([]*rule, error) {
+	r := &rule{}
+	var additional []*rule
+
+	err := walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType {
+			return protowire.ConsumeFieldValue(num, typ, b), nil
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+
+		switch num {
+		case ruleGet:
+			r.method, r.path = http.MethodGet, string(v)
+		case rulePut:
+			r.method, r.path = http.MethodPut, string(v)
+		case rulePost:
+			r.method, r.path = http.MethodPost, string(v)
+		case ruleDelete:
+			r.method, r.path = http.MethodDelete, string(v)
+		case rulePatch:
+			r.method, r.path = http.MethodPatch, string(v)
+		case ruleBody:
+			r.body = string(v)
+		case ruleResponseBody:
+			r.responseBody = string(v)
+		case ruleCustom:
+			errC := walk(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				if typ != protowire.BytesType {
+					return protowire.ConsumeFieldValue(num, typ, b), nil
+				}
+
+				cv, cn := protowire.ConsumeBytes(b)
+				switch num {
+				case customKind:
+					r.method = string(cv)
+				case customPath:
+					r.path = string(cv)
+				}
+
+				return cn, nil
+			})
+			if errC != nil {
+				return 0, errC
+			}
+		case ruleAdditionalBindings:
+			if top {
+				nested, errN := parseRule(v, false)
+				if errN != nil {
+					return 0, errN
+				}
+				additional = append(additional, nested...)
+			}
+		}
+
+		return n, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if r.method == "" || r.path == "" {
+		return additional, nil
+	}
+
+	return append([]*rule{r}, additional...), nil
+}
+
+// walk calls fn for every field of the message, fn returns the number of consumed bytes of the field value.
+func walk(in []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(in) > 0 {
+		num, typ, n := protowire.ConsumeTag(in)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		in = in[n:]
+
+		n, err := fn(num, typ, in)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		in = in[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package transcoding
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/roadrunner-server/errors"
+)
+
+type segmentKind int
+
+const (
+	literal segmentKind = iota
+	// `*`, matches a single segment
+	single
+	// `**`, matches zero or more segments
+	multi
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+}
+
+// variable binds the path segments [start, end) to the field.
+type variable struct {
+	field []string
+	start int
+	end   int
+}
+
+// template is a parsed google.api.http path template:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	FieldPath = IDENT { "." IDENT } ;
+//	Verb     = ":" LITERAL ;
+type template struct {
+	segments  []segment
+	variables []variable
+	verb      string
+}
+
+func parseTemplate(tmpl string) (*template, error) {
+	if !strings.HasPrefix(tmpl, "/") {
+		return nil, errors.Errorf("path template should start with /: %s", tmpl)
+	}
+
+	t := &template{}
+	rest := tmpl[1:]
+
+	// the verb follows the last segment, it could not be inside a variable
+	if i := strings.LastIndex(rest, ":"); i >= 0 && i > strings.LastIndex(rest, "/") && i > strings.LastIndex(rest, "}") {
+		t.verb = rest[i+1:]
+		rest = rest[:i]
+	}
+
+	for len(rest) > 0 {
+		if rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return nil, errors.Errorf("unclosed variable in the path template: %s", tmpl)
+			}
+
+			field, pattern, ok := strings.Cut(rest[1:end], "=")
+			if !ok {
+				pattern = "*"
+			}
+
+			if field == "" || pattern == "" || strings.ContainsAny(pattern, "{}") {
+				return nil, errors.Errorf("malformed variable in the path template: %s", tmpl)
+			}
+
+			v := variable{field: strings.Split(field, "."), start: len(t.segments)}
+			for p := range strings.SplitSeq(pattern, "/") {
+				t.segments = append(t.segments, newSegment(p))
+			}
+			v.end = len(t.segments)
+			t.variables = append(t.variables, v)
+
+			rest = rest[end+1:]
+		} else {
+			end := strings.IndexByte(rest, '/')
+			if end < 0 {
+				end = len(rest)
+			}
+
+			if strings.ContainsAny(rest[:end], "{}") {
+				return nil, errors.Errorf("malformed segment in the path template: %s", tmpl)
+			}
+
+			t.segments = append(t.segments, newSegment(rest[:end]))
+			rest = rest[end:]
+		}
+
+		if len(rest) > 0 {
+			if rest[0] != '/' || len(rest) == 1 {
+				return nil, errors.Errorf("malformed path template: %s", tmpl)
+			}
+			rest = rest[1:]
+		}
+	}
+
+	multis := 0
+	for _, s := range t.segments {
+		if s.kind == multi {
+			multis++
+		}
+		if s.kind == literal && s.value == "" {
+			return nil, errors.Errorf("empty segment in the path template: %s", tmpl)
+		}
+	}
+
+	if multis > 1 {
+		return nil, errors.Errorf("only a single ** is allowed in the path template: %s", tmpl)
+	}
+
+	return t, nil
+}
+
+func newSegment(s string) segment {
+	switch s {
+	case "*":
+		return segment{kind: single}
+	case "**":
+		return segment{kind: multi}
+	default:
+		return segment{kind: literal, value: s}
+	}
+}
+
+// keepSlash escapes the escaped / once more, so it is kept after the unescaping
+var keepSlash = strings.NewReplacer("%2F", "%252F", "%2f", "%252f") //nolint:gochecknoglobals
+
+// match matches the escaped request path and returns the values of the variables.
+func (t *template) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+
+	if t.verb != "" {
+		var ok bool
+		path, ok = strings.CutSuffix(path, ":"+t.verb)
+		if !ok {
+			return nil, false
+		}
+	}
+
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	// pos[i] is the index of the first path part matched by the template segment i
+	pos := make([]int, len(t.segments)+1)
+	j := 0
+	for i, s := range t.segments {
+		pos[i] = j
+
+		switch s.kind {
+		case multi:
+			// the rest of the template matches a single part per segment
+			n := len(parts) - j - (len(t.segments) - i - 1)
+			if n < 0 {
+				return nil, false
+			}
+			j += n
+		case single:
+			if j >= len(parts) || parts[j] == "" {
+				return nil, false
+			}
+			j++
+		case literal:
+			if j >= len(parts) {
+				return nil, false
+			}
+
+			p, err := url.PathUnescape(parts[j])
+			if err != nil || p != s.value {
+				return nil, false
+			}
+			j++
+		}
+	}
+
+	if j != len(parts) {
+		return nil, false
+	}
+	pos[len(t.segments)] = j
+
+	values := make(map[string]string, len(t.variables))
+	for _, v := range t.variables {
+		matched := parts[pos[v.start]:pos[v.end]]
+
+		var value string
+		if v.end-v.start == 1 && t.segments[v.start].kind != multi {
+			// a single segment variable, the reserved characters (including /) are unescaped
+			var err error
+			value, err = url.PathUnescape(matched[0])
+			if err != nil {
+				return nil, false
+			}
+		} else {
+			// a multi segment variable, the escaped / is kept
+			unescaped := make([]string, len(matched))
+			for k, m := range matched {
+				u, err := url.PathUnescape(keepSlash.Replace(m))
+				if err != nil {
+					return nil, false
+				}
+				unescaped[k] = u
+			}
+			value = strings.Join(unescaped, "/")
+		}
+
+		values[strings.Join(v.field, ".")] = value
+	}
+
+	return values, true
+}
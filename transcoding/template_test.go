@@ -0,0 +1,68 @@
+package transcoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := parseTemplate("/v1/{name=shelves/*/books/*}:publish")
+	require.NoError(t, err)
+	assert.Equal(t, "publish", tmpl.verb)
+	assert.Len(t, tmpl.segments, 5)
+	require.Len(t, tmpl.variables, 1)
+	assert.Equal(t, variable{field: []string{"name"}, start: 1, end: 5}, tmpl.variables[0])
+
+	tmpl, err = parseTemplate("/v1/{book.id}/files/**")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"book", "id"}, tmpl.variables[0].field)
+	assert.Equal(t, multi, tmpl.segments[3].kind)
+
+	for _, bad := range []string{
+		"v1/books",
+		"/v1/{name",
+		"/v1/{=books}",
+		"/v1//books",
+		"/v1/books/",
+		"/v1/**/books/**",
+		"/v1/{name={id}}",
+	} {
+		_, err = parseTemplate(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		values   map[string]string
+		ok       bool
+	}{
+		{"/v1/books", "/v1/books", map[string]string{}, true},
+		{"/v1/books", "/v1/books/1", nil, false},
+		{"/v1/books/{id}", "/v1/books/42", map[string]string{"id": "42"}, true},
+		{"/v1/books/{id}", "/v1/books/", nil, false},
+		{"/v1/books/{id}", "/v1/books/a%2Fb", map[string]string{"id": "a/b"}, true},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/books/2", map[string]string{"name": "shelves/1/books/2"}, true},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/books", nil, false},
+		{"/v1/{name=files/**}", "/v1/files/a/b%2Fc", map[string]string{"name": "files/a/b%2Fc"}, true},
+		{"/v1/{path=**}/raw", "/v1/a/b/raw", map[string]string{"path": "a/b"}, true},
+		{"/v1/{name=books/*}:publish", "/v1/books/1:publish", map[string]string{"name": "books/1"}, true},
+		{"/v1/{name=books/*}:publish", "/v1/books/1", nil, false},
+		{"/v1/hello", "/v1/hell%6F", map[string]string{}, true},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := parseTemplate(tt.template)
+		require.NoError(t, err, tt.template)
+
+		values, ok := tmpl.match(tt.path)
+		assert.Equal(t, tt.ok, ok, "%s %s", tt.template, tt.path)
+		if tt.ok {
+			assert.Equal(t, tt.values, values, "%s %s", tt.template, tt.path)
+		}
+	}
+}